@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AdminRequest is the JSON payload accepted by POST /api/admin.
+type AdminRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	IsSudo   bool   `json:"is_sudo"`
+	// TelegramID and DiscordWebhook are optional notification targets.
+	TelegramID     *int64  `json:"telegram_id,omitempty"`
+	DiscordWebhook *string `json:"discord_webhook,omitempty"`
+}
+
+func (m *marzban) CreateAdmin(req AdminRequest) error {
+	return m.CreateAdminCtx(context.Background(), req)
+}
+
+func (m *marzban) CreateAdminCtx(ctx context.Context, req AdminRequest) error {
+	return m.do(ctx, "POST", API_ADMIN_PATH, req, nil)
+}
+
+func (m *marzban) ListAdmins() ([]Admin, error) {
+	return m.ListAdminsCtx(context.Background())
+}
+
+func (m *marzban) ListAdminsCtx(ctx context.Context) ([]Admin, error) {
+	var admins []Admin
+	err := m.do(ctx, "GET", API_ADMINS_PATH, nil, &admins)
+	return admins, err
+}
+
+// GetAdmin returns username's settings, e.g. to check that CreateAdmin gave
+// it the intended sudo status. Panels without GET /api/admin/{username} are
+// served from the admin list instead.
+func (m *marzban) GetAdmin(username string) (Admin, error) {
+	return m.GetAdminCtx(context.Background(), username)
+}
+
+func (m *marzban) GetAdminCtx(ctx context.Context, username string) (Admin, error) {
+	var admin Admin
+	err := m.do(ctx, "GET", adminPath(username), nil, &admin)
+	if err == nil {
+		return admin, nil
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed) {
+		return m.findAdmin(ctx, username)
+	}
+
+	return Admin{}, err
+}
+
+func (m *marzban) DeleteAdmin(username string) error {
+	return m.DeleteAdminCtx(context.Background(), username)
+}
+
+func (m *marzban) DeleteAdminCtx(ctx context.Context, username string) error {
+	err := m.do(ctx, "DELETE", adminPath(username), nil, nil)
+	return adminNotFound(err, username)
+}
+
+// adminModifyBody is the JSON payload accepted by PUT /api/admin/{username}.
+// The panel requires is_sudo, so the current value is sent back unchanged.
+type adminModifyBody struct {
+	Password       string  `json:"password"`
+	IsSudo         bool    `json:"is_sudo"`
+	TelegramID     *int    `json:"telegram_id,omitempty"`
+	DiscordWebhook *string `json:"discord_webhook,omitempty"`
+}
+
+// ChangeAdminPassword sets a new password for username, keeping its other
+// settings. When username is the admin this client logs in as, the client
+// switches to the new password so later calls keep working.
+func (m *marzban) ChangeAdminPassword(username, newPassword string) error {
+	return m.ChangeAdminPasswordCtx(context.Background(), username, newPassword)
+}
+
+func (m *marzban) ChangeAdminPasswordCtx(ctx context.Context, username, newPassword string) error {
+	if newPassword == "" {
+		return errors.New("new password is empty")
+	}
+
+	admin, err := m.findAdmin(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	body := adminModifyBody{
+		Password:       newPassword,
+		IsSudo:         admin.IsSudo,
+		DiscordWebhook: admin.DiscordWebhook,
+	}
+	if admin.TelegramID != 0 {
+		body.TelegramID = &admin.TelegramID
+	}
+
+	err = m.do(ctx, "PUT", adminPath(username), body, nil)
+	if err != nil {
+		return adminNotFound(err, username)
+	}
+
+	m.mu.Lock()
+	if username == m.config.Username {
+		// The panel rejects tokens issued before a password change.
+		m.config.Password = newPassword
+		m.token = ""
+		m.tokenAt = time.Time{}
+	}
+	m.mu.Unlock()
+
+	m.config.Logger.Info("admin password changed", "username", username)
+	return nil
+}
+
+// findAdmin looks username up through GET /api/admins, which filters by
+// substring, so the exact match is picked out here.
+func (m *marzban) findAdmin(ctx context.Context, username string) (Admin, error) {
+	var admins []Admin
+	path := API_ADMINS_PATH + "?" + url.Values{"username": {username}}.Encode()
+	err := m.do(ctx, "GET", path, nil, &admins)
+	if err != nil {
+		return Admin{}, err
+	}
+
+	for _, admin := range admins {
+		if admin.Username == username {
+			return admin, nil
+		}
+	}
+
+	return Admin{}, fmt.Errorf("%w: %s", ErrAdminNotFound, username)
+}
+
+func adminPath(username string) string {
+	return API_ADMIN_PATH + "/" + url.PathEscape(username)
+}
+
+// adminNotFound reports a 404 from an admin endpoint as ErrAdminNotFound.
+func adminNotFound(err error, username string) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s: %w", ErrAdminNotFound, username, err)
+	}
+
+	return err
+}
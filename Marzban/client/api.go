@@ -1,190 +1,1340 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"maps"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"google.golang.org/protobuf/types/known/timestamppb"
+	"golang.org/x/time/rate"
 )
 
+// Marzban is a client for the Marzban panel API. Each method has a Ctx
+// variant that honours the caller's context for cancellation and deadlines;
+// the plain form uses context.Background(). A client is safe for concurrent
+// use by multiple goroutines, which share its token and connections.
 type Marzban interface {
-	CreateMarzbanUser(username string) (Response, error)
+	CreateMarzbanUser(req CreateUserRequest) (Response, error)
+	CreateMarzbanUserCtx(ctx context.Context, req CreateUserRequest) (Response, error)
+	CreateMarzbanUsers(reqs []CreateUserRequest) ([]CreateResult, error)
+	CreateMarzbanUsersCtx(ctx context.Context, reqs []CreateUserRequest) ([]CreateResult, error)
+	GetMarzbanUser(username string) (User, error)
+	GetMarzbanUserCtx(ctx context.Context, username string) (User, error)
+	UpdateMarzbanUser(username string, req UpdateUserRequest) (Response, error)
+	UpdateMarzbanUserCtx(ctx context.Context, username string, req UpdateUserRequest) (Response, error)
+	SetUserProxySettings(username string, proxies map[string]ProxySettings) error
+	SetUserProxySettingsCtx(ctx context.Context, username string, proxies map[string]ProxySettings) error
+	PatchUserProxySettings(username string, patches map[string]ProxySettingsPatch) error
+	PatchUserProxySettingsCtx(ctx context.Context, username string, patches map[string]ProxySettingsPatch) error
+	EnableUser(username string) error
+	EnableUserCtx(ctx context.Context, username string) error
+	DisableUser(username string) error
+	DisableUserCtx(ctx context.Context, username string) error
+	DeleteMarzbanUser(username string) error
+	DeleteMarzbanUserCtx(ctx context.Context, username string) error
+	DeleteUserAndRestart(username string) error
+	DeleteUserAndRestartCtx(ctx context.Context, username string) error
+	ResetUserDataUsage(username string) error
+	ResetUserDataUsageCtx(ctx context.Context, username string) error
+	ListMarzbanUsers(offset, limit int) ([]User, int, error)
+	ListMarzbanUsersCtx(ctx context.Context, offset, limit int) ([]User, int, error)
+	ListAllMarzbanUsers() ([]User, error)
+	ListAllMarzbanUsersCtx(ctx context.Context) ([]User, error)
+	ListMarzbanUsersWithOptions(opts ListUsersOptions) ([]User, int, error)
+	ListMarzbanUsersWithOptionsCtx(ctx context.Context, opts ListUsersOptions) ([]User, int, error)
+	ListAllMarzbanUsersWithOptions(opts ListUsersOptions) ([]User, error)
+	ListAllMarzbanUsersWithOptionsCtx(ctx context.Context, opts ListUsersOptions) ([]User, error)
+	ExportUsers(w io.Writer, format ExportFormat) error
+	ExportUsersCtx(ctx context.Context, w io.Writer, format ExportFormat) error
+	ImportUsers(r io.Reader, format ExportFormat) ([]ImportResult, error)
+	ImportUsersCtx(ctx context.Context, r io.Reader, format ExportFormat) ([]ImportResult, error)
+	DeleteExpiredUsers() (int, error)
+	DeleteExpiredUsersCtx(ctx context.Context) (int, error)
+	DeleteExpiredUsersDryRun() ([]string, error)
+	DeleteExpiredUsersDryRunCtx(ctx context.Context) ([]string, error)
+	RevokeSubscription(username string) (Response, error)
+	RevokeSubscriptionCtx(ctx context.Context, username string) (Response, error)
+	GetSubscription(username string) (Subscription, error)
+	GetSubscriptionCtx(ctx context.Context, username string) (Subscription, error)
+	GetUserSummary(username string) (UserSummary, error)
+	GetUserSummaryCtx(ctx context.Context, username string) (UserSummary, error)
+	GetSystemStats() (SystemStats, error)
+	GetSystemStatsCtx(ctx context.Context) (SystemStats, error)
+	ListNodes() ([]Node, error)
+	ListNodesCtx(ctx context.Context) ([]Node, error)
+	AddNode(req AddNodeRequest) (Node, error)
+	AddNodeCtx(ctx context.Context, req AddNodeRequest) (Node, error)
+	RemoveNode(id int) error
+	RemoveNodeCtx(ctx context.Context, id int) error
+	GetNodeSettings() (NodeSettings, error)
+	GetNodeSettingsCtx(ctx context.Context) (NodeSettings, error)
+	CreateAdmin(req AdminRequest) error
+	CreateAdminCtx(ctx context.Context, req AdminRequest) error
+	ListAdmins() ([]Admin, error)
+	ListAdminsCtx(ctx context.Context) ([]Admin, error)
+	GetAdmin(username string) (Admin, error)
+	GetAdminCtx(ctx context.Context, username string) (Admin, error)
+	DeleteAdmin(username string) error
+	DeleteAdminCtx(ctx context.Context, username string) error
+	ChangeAdminPassword(username, newPassword string) error
+	ChangeAdminPasswordCtx(ctx context.Context, username, newPassword string) error
+	GetUserUsage(username string, start, end time.Time) (UsageSeries, error)
+	GetUserUsageCtx(ctx context.Context, username string, start, end time.Time) (UsageSeries, error)
+	GetInbounds() (map[string][]Inbound, error)
+	GetInboundsCtx(ctx context.Context) (map[string][]Inbound, error)
+	GetCoreConfig() (json.RawMessage, error)
+	GetCoreConfigCtx(ctx context.Context) (json.RawMessage, error)
+	UpdateCoreConfig(cfg json.RawMessage) error
+	UpdateCoreConfigCtx(ctx context.Context, cfg json.RawMessage) error
+	RestartCore() error
+	RestartCoreCtx(ctx context.Context) error
+	Ping() error
+	PingCtx(ctx context.Context) error
+	WaitForPanel(ctx context.Context, interval time.Duration) error
+	InvalidateToken()
+	Close() error
 }
 
-type marzban struct{}
+// Config holds the panel connection settings. Username and Password fall
+// back to DEFAULT_USERNAME/DEFAULT_PASSWORD (admin/admin) only when both are
+// left empty, matching the credentials of a fresh Marzban install.
+type Config struct {
+	BaseURL  string
+	Username string
+	Password string
+	// Timeout bounds every request to the panel; 0 means DEFAULT_TIMEOUT.
+	Timeout time.Duration
+	// HTTPClient, when set, is used for every request instead of a client
+	// built from Timeout, e.g. to route through a proxy or an httptest server.
+	// The caller keeps ownership of it; Close leaves it alone.
+	HTTPClient *http.Client
+	// InsecureSkipVerify disables TLS certificate verification so the client
+	// can reach a panel still using a self-signed certificate. This leaves
+	// the connection, including the admin credentials, open to interception;
+	// only enable it on trusted networks. Ignored when HTTPClient is set.
+	InsecureSkipVerify bool
+	// RateLimit caps outbound requests per second, including logins, to stay
+	// under panel or proxy throttles during bulk work; 0 disables limiting.
+	RateLimit float64
+	// MaxRetries is how many times a request failing with a network error or
+	// a 502/503/504 is retried; 0 means DEFAULT_MAX_RETRIES and a negative
+	// value disables retries.
+	MaxRetries int
+	// Logger receives request failures and lifecycle events; nil discards
+	// them.
+	Logger *slog.Logger
+	// Webhook, when its URL is set, is POSTed a WebhookEvent after each
+	// successful CreateMarzbanUser.
+	Webhook WebhookConfig
+	// Headers are added to every request to the panel, logins included,
+	// e.g. the CF-Access-Client-Id/CF-Access-Client-Secret pair of a panel
+	// behind Cloudflare Access. They never replace Authorization.
+	Headers map[string]string
+}
+
+// Option adjusts the Config used by NewMarzbanClient. Options left out keep
+// the defaults of NewMarzbanClientWithConfig.
+type Option func(*Config)
 
-func NewMarzbanClient() Marzban {
-	return &marzban{}
+func WithBaseURL(baseURL string) Option {
+	return func(cfg *Config) {
+		cfg.BaseURL = baseURL
+	}
 }
 
-func (m *marzban) CreateMarzbanUser(username string) (Response, error) {
-	var resp *http.Response
-	var response Response
+func WithCredentials(username, password string) Option {
+	return func(cfg *Config) {
+		cfg.Username = username
+		cfg.Password = password
+	}
+}
+
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *Config) {
+		cfg.HTTPClient = c
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.Timeout = timeout
+	}
+}
+
+func WithLogger(l *slog.Logger) Option {
+	return func(cfg *Config) {
+		cfg.Logger = l
+	}
+}
+
+// WithHeader adds one entry to Config.Headers.
+func WithHeader(key, value string) Option {
+	return func(cfg *Config) {
+		if cfg.Headers == nil {
+			cfg.Headers = map[string]string{}
+		}
+		cfg.Headers[key] = value
+	}
+}
+
+// The concrete client must keep satisfying Marzban so consumers can depend
+// on the interface and mock it.
+var _ Marzban = (*marzban)(nil)
 
-	token, err := auth()
+type marzban struct {
+	config  Config
+	http    *http.Client
+	limiter *rate.Limiter
+
+	// mu guards the token cache and config.Password, which
+	// ChangeAdminPassword rotates; everything else is read-only after
+	// construction.
+	mu      sync.RWMutex
+	token   string
+	tokenAt time.Time
+
+	// ownsHTTP is set when http was built by the client, so Close may shut
+	// down its connections.
+	ownsHTTP bool
+	closed   atomic.Bool
+
+	// webhookHTTP delivers webhooks. It is separate from http so that
+	// InsecureSkipVerify, meant for the panel's self-signed certificate,
+	// never applies to the third-party webhook URL.
+	webhookHTTP *http.Client
+}
+
+// NewMarzbanClient builds a client from functional options. Without any it
+// talks to DEFAULT_BASE_URL as admin/admin, like a fresh install.
+func NewMarzbanClient(opts ...Option) Marzban {
+	var cfg Config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return NewMarzbanClientWithConfig(cfg)
+}
+
+func NewMarzbanClientWithConfig(cfg Config) Marzban {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DEFAULT_BASE_URL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Username == "" && cfg.Password == "" {
+		cfg.Username = DEFAULT_USERNAME
+		cfg.Password = DEFAULT_PASSWORD
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DEFAULT_TIMEOUT
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DEFAULT_MAX_RETRIES
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.DiscardHandler)
+	}
+	cfg.Headers = maps.Clone(cfg.Headers)
+	for key := range cfg.Headers {
+		if http.CanonicalHeaderKey(key) == "Authorization" {
+			cfg.Logger.Warn("ignoring custom Authorization header; the client sets its own")
+		}
+	}
+
+	httpClient := cfg.HTTPClient
+	ownsHTTP := httpClient == nil
+	if ownsHTTP {
+		// A transport of our own keeps Close from touching the idle
+		// connections of http.DefaultTransport.
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if cfg.InsecureSkipVerify {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		httpClient = &http.Client{Timeout: cfg.Timeout, Transport: transport}
+	}
+
+	var limiter *rate.Limiter
+	if cfg.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.RateLimit), max(1, int(cfg.RateLimit)))
+	}
+
+	return &marzban{
+		config:   cfg,
+		http:     httpClient,
+		limiter:  limiter,
+		ownsHTTP: ownsHTTP,
+		webhookHTTP: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		},
+	}
+}
+
+func (m *marzban) url(path string) string {
+	return m.config.BaseURL + path
+}
+
+// wait blocks until the rate limiter, if any, allows another request.
+func (m *marzban) wait(ctx context.Context) error {
+	if m.limiter == nil {
+		return nil
+	}
+
+	return m.limiter.Wait(ctx)
+}
+
+// accessToken returns the cached token, logging in again when none is cached
+// or the cached one is about to expire.
+func (m *marzban) accessToken(ctx context.Context) (string, error) {
+	m.mu.RLock()
+	token, fresh := m.cachedToken()
+	m.mu.RUnlock()
+	if fresh {
+		return token, nil
+	}
+
+	// Holding the write lock through the login makes concurrent callers wait
+	// for a single login instead of each starting their own.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, fresh = m.cachedToken()
+	if fresh {
+		return token, nil
+	}
+
+	token, err := m.auth(ctx)
 	if err != nil {
-		return response, err
+		return "", err
 	}
 
-	data := strings.NewReader(`{
-	  "username": ` + username + `,
-	  "proxies": {
-	    "vless": ""
-	  },
-	  "expire": 0,
-	  "data_limit": 0,
-	  "data_limit_reset_strategy": "no_reset",
-	  "status": "active",
-	  "note": "",
-	  "on_hold_timeout": "2023-11-03T20:30:00",
-	  "on_hold_expire_duration": 0
-	}`)
-	req, err := http.NewRequest("POST", API_CREATE_USER, data)
+	m.token = token
+	m.tokenAt = time.Now()
+	return token, nil
+}
+
+// cachedToken reports the cached token and whether it is still usable. The
+// caller holds mu.
+func (m *marzban) cachedToken() (string, bool) {
+	fresh := m.token != "" && time.Since(m.tokenAt) < TOKEN_LIFETIME-TOKEN_REFRESH_MARGIN
+	return m.token, fresh
+}
+
+// InvalidateToken drops the cached token so the next call logs in again.
+func (m *marzban) InvalidateToken() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.token = ""
+	m.tokenAt = time.Time{}
+}
+
+// Close drops the cached token and closes the idle connections of the HTTP
+// client it built, for long-lived programs that create and discard
+// clients. The client is unusable afterwards: every call returns
+// ErrClientClosed. Closing twice is a no-op.
+func (m *marzban) Close() error {
+	if m.closed.Swap(true) {
+		return nil
+	}
+
+	m.InvalidateToken()
+	if m.ownsHTTP {
+		m.http.CloseIdleConnections()
+	}
+	m.webhookHTTP.CloseIdleConnections()
+
+	return nil
+}
+
+func (m *marzban) CreateMarzbanUser(req CreateUserRequest) (Response, error) {
+	return m.CreateMarzbanUserCtx(context.Background(), req)
+}
+
+func (m *marzban) CreateMarzbanUserCtx(ctx context.Context, req CreateUserRequest) (Response, error) {
+	var response Response
+
+	err := ValidateUsername(req.Username)
 	if err != nil {
 		return response, err
 	}
 
-	req.Header.Set("accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
+	if req.DataLimit < 0 {
+		return response, fmt.Errorf("invalid data limit %d", req.DataLimit)
+	}
+	if req.Expire != 0 && req.Expire <= time.Now().Unix() {
+		return response, fmt.Errorf("expiry %d is not in the future", req.Expire)
+	}
 
-	client := &http.Client{}
-	resp, err = client.Do(req)
-	if resp == nil {
-		return response, errors.New("FAILED REQUEST | " + API_CREATE_USER)
+	proxies := req.Proxies
+	if len(proxies) == 0 {
+		proxies = map[string]ProxySettings{PROTOCOL_VLESS: {}}
+	}
+	for protocol := range proxies {
+		if !slices.Contains(PROTOCOLS, protocol) {
+			return response, fmt.Errorf("unsupported protocol %q", protocol)
+		}
 	}
+
+	strategy := req.DataLimitResetStrategy
+	if strategy == "" {
+		strategy = RESET_STRATEGY_NO_RESET
+	}
+	if !slices.Contains(RESET_STRATEGIES, strategy) {
+		return response, fmt.Errorf("unsupported data limit reset strategy %q", strategy)
+	}
+
+	status := req.Status
+	if status == "" {
+		status = USER_STATUS_ACTIVE
+	}
+	switch status {
+	case USER_STATUS_ACTIVE:
+		if req.OnHoldExpireDuration != 0 {
+			return response, errors.New("on_hold_expire_duration is only valid for on_hold users")
+		}
+	case USER_STATUS_ON_HOLD:
+		if req.OnHoldExpireDuration <= 0 {
+			return response, errors.New("on_hold users need a positive on_hold_expire_duration")
+		}
+		if req.Expire != 0 {
+			return response, errors.New("on_hold users cannot have an expiry; use on_hold_expire_duration")
+		}
+	default:
+		return response, fmt.Errorf("users cannot be created with status %q", status)
+	}
+
+	data, err := json.Marshal(createUserBody{
+		Username:               req.Username,
+		Proxies:                proxies,
+		Expire:                 req.Expire,
+		DataLimit:              int64(req.DataLimit),
+		DataLimitResetStrategy: strategy,
+		Status:                 status,
+		Note:                   req.Note,
+		OnHoldExpireDuration:   req.OnHoldExpireDuration,
+	})
 	if err != nil {
 		return response, err
 	}
 
-	body, _ := io.ReadAll(resp.Body)
-	err = json.Unmarshal(body, &response)
+	resp, err := m.send(ctx, "POST", API_USER_PATH, data)
 	if err != nil {
-		log.Println(err)
+		return response, err
 	}
 
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Println(err)
+			m.config.Logger.Warn("closing response body", "error", err)
 		}
 	}(resp.Body)
 
+	if err := m.statusError(resp, API_USER_PATH); err != nil {
+		return response, err
+	}
+
+	err = m.decode(resp, API_USER_PATH, &response)
+	if err != nil {
+		return response, err
+	}
+	m.absoluteSubscriptionURL(&response)
+
+	m.config.Logger.Info("user created", "username", req.Username)
+	m.notifyWebhook(ctx, WebhookEvent{
+		Event:    WEBHOOK_EVENT_USER_CREATED,
+		Username: response.Username,
+		Links:    response.Links,
+		Expire:   response.Expire,
+	})
 	return response, nil
 }
 
-func auth() (string, error) {
-	var resp *http.Response
-	payload := strings.NewReader(`grant_type=&username=admin&password=admin&scope=&client_id=&client_secret=`)
-	req, err := http.NewRequest("POST", API_AUTH_URL, payload)
+func (m *marzban) GetMarzbanUser(username string) (User, error) {
+	return m.GetMarzbanUserCtx(context.Background(), username)
+}
+
+func (m *marzban) GetMarzbanUserCtx(ctx context.Context, username string) (User, error) {
+	var user User
+
+	path := userPath(username)
+	resp, err := m.send(ctx, "GET", path, nil)
 	if err != nil {
-		return "", err
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if err := m.userStatusError(resp, path, username); err != nil {
+		return user, err
+	}
+
+	err = m.decode(resp, path, &user)
+	m.absoluteSubscriptionURL(&user)
+	return user, err
+}
+
+// UpdateMarzbanUser changes only the fields set in req; nil fields are left
+// untouched by the panel.
+func (m *marzban) UpdateMarzbanUser(username string, req UpdateUserRequest) (Response, error) {
+	return m.UpdateMarzbanUserCtx(context.Background(), username, req)
+}
+
+func (m *marzban) UpdateMarzbanUserCtx(ctx context.Context, username string, req UpdateUserRequest) (Response, error) {
+	var response Response
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return response, err
+	}
+
+	path := userPath(username)
+	resp, err := m.send(ctx, "PUT", path, data)
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+
+	if err := m.userStatusError(resp, path, username); err != nil {
+		return response, err
+	}
+
+	err = m.decode(resp, path, &response)
+	m.absoluteSubscriptionURL(&response)
+	return response, err
+}
+
+// SetUserProxySettings merges proxies into the user's current proxy
+// settings, e.g. to set Flow to FLOW_XTLS_RPRX_VISION on vless. Only the
+// non-empty fields given are changed, so existing IDs and passwords, and
+// with them the user's links, survive; protocols not mentioned are kept.
+// A protocol the user does not have yet is added. Use
+// PatchUserProxySettings to clear a field.
+func (m *marzban) SetUserProxySettings(username string, proxies map[string]ProxySettings) error {
+	return m.SetUserProxySettingsCtx(context.Background(), username, proxies)
+}
+
+func (m *marzban) SetUserProxySettingsCtx(ctx context.Context, username string, proxies map[string]ProxySettings) error {
+	patches := make(map[string]ProxySettingsPatch, len(proxies))
+	for protocol, settings := range proxies {
+		patches[protocol] = ProxySettingsPatch{
+			ID:       nonEmpty(settings.ID),
+			Flow:     nonEmpty(settings.Flow),
+			Password: nonEmpty(settings.Password),
+			Method:   nonEmpty(settings.Method),
+		}
+	}
+
+	return m.PatchUserProxySettingsCtx(ctx, username, patches)
+}
+
+// PatchUserProxySettings is SetUserProxySettings with explicit fields: only
+// the non-nil fields of each patch are written, and a pointer to "" clears
+// the field, e.g. to remove FLOW_XTLS_RPRX_VISION from vless.
+func (m *marzban) PatchUserProxySettings(username string, patches map[string]ProxySettingsPatch) error {
+	return m.PatchUserProxySettingsCtx(context.Background(), username, patches)
+}
+
+func (m *marzban) PatchUserProxySettingsCtx(ctx context.Context, username string, patches map[string]ProxySettingsPatch) error {
+	for protocol := range patches {
+		if !slices.Contains(PROTOCOLS, protocol) {
+			return fmt.Errorf("unsupported protocol %q", protocol)
+		}
+	}
+
+	user, err := m.GetMarzbanUserCtx(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	// The panel replaces the whole map and regenerates any ID left empty.
+	merged := maps.Clone(user.Proxies)
+	if merged == nil {
+		merged = map[string]ProxySettings{}
+	}
+	for protocol, patch := range patches {
+		merged[protocol] = patch.apply(merged[protocol])
+	}
+
+	_, err = m.UpdateMarzbanUserCtx(ctx, username, UpdateUserRequest{Proxies: merged})
+	return err
+}
+
+func (p ProxySettingsPatch) apply(settings ProxySettings) ProxySettings {
+	if p.ID != nil {
+		settings.ID = *p.ID
+	}
+	if p.Flow != nil {
+		settings.Flow = *p.Flow
+	}
+	if p.Password != nil {
+		settings.Password = *p.Password
+	}
+	if p.Method != nil {
+		settings.Method = *p.Method
+	}
+
+	return settings
+}
+
+// nonEmpty returns a pointer to s, or nil when s is empty.
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+// EnableUser reactivates a user suspended with DisableUser.
+func (m *marzban) EnableUser(username string) error {
+	return m.EnableUserCtx(context.Background(), username)
+}
+
+func (m *marzban) EnableUserCtx(ctx context.Context, username string) error {
+	return m.setUserStatus(ctx, username, USER_STATUS_ACTIVE)
+}
+
+// DisableUser suspends a user without deleting the account.
+func (m *marzban) DisableUser(username string) error {
+	return m.DisableUserCtx(context.Background(), username)
+}
+
+func (m *marzban) DisableUserCtx(ctx context.Context, username string) error {
+	return m.setUserStatus(ctx, username, USER_STATUS_DISABLED)
+}
+
+func (m *marzban) setUserStatus(ctx context.Context, username, status string) error {
+	_, err := m.UpdateMarzbanUserCtx(ctx, username, UpdateUserRequest{Status: &status})
+	return err
+}
+
+func (m *marzban) DeleteMarzbanUser(username string) error {
+	return m.DeleteMarzbanUserCtx(context.Background(), username)
+}
+
+func (m *marzban) DeleteMarzbanUserCtx(ctx context.Context, username string) error {
+	path := userPath(username)
+	resp, err := m.send(ctx, "DELETE", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return m.userStatusError(resp, path, username)
+}
+
+// DeleteUserAndRestart deletes the user and then restarts the core, since
+// xray can keep serving connections that were open before the delete. The
+// restart drops every user's connections briefly, so use it when cutting
+// someone off matters more than that.
+func (m *marzban) DeleteUserAndRestart(username string) error {
+	return m.DeleteUserAndRestartCtx(context.Background(), username)
+}
+
+func (m *marzban) DeleteUserAndRestartCtx(ctx context.Context, username string) error {
+	err := m.DeleteMarzbanUserCtx(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	err = m.RestartCoreCtx(ctx)
+	if err != nil {
+		return fmt.Errorf("user %s deleted but restarting the core failed: %w", username, err)
+	}
+
+	return nil
+}
+
+// ResetUserDataUsage zeroes the traffic the user has consumed so far.
+func (m *marzban) ResetUserDataUsage(username string) error {
+	return m.ResetUserDataUsageCtx(context.Background(), username)
+}
+
+func (m *marzban) ResetUserDataUsageCtx(ctx context.Context, username string) error {
+	path := userPath(username) + "/reset"
+	resp, err := m.send(ctx, "POST", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return m.userStatusError(resp, path, username)
+}
+
+// ListMarzbanUsers returns one page of users along with the total number of
+// users known to the panel.
+func (m *marzban) ListMarzbanUsers(offset, limit int) ([]User, int, error) {
+	return m.ListMarzbanUsersCtx(context.Background(), offset, limit)
+}
+
+func (m *marzban) ListMarzbanUsersCtx(ctx context.Context, offset, limit int) ([]User, int, error) {
+	return m.ListMarzbanUsersWithOptionsCtx(ctx, ListUsersOptions{Offset: offset, Limit: limit})
+}
+
+// ListMarzbanUsersWithOptions returns one page of the users matching opts,
+// filtered by the panel, along with the number of matching users.
+func (m *marzban) ListMarzbanUsersWithOptions(opts ListUsersOptions) ([]User, int, error) {
+	return m.ListMarzbanUsersWithOptionsCtx(context.Background(), opts)
+}
+
+func (m *marzban) ListMarzbanUsersWithOptionsCtx(ctx context.Context, opts ListUsersOptions) ([]User, int, error) {
+	var page usersResponse
+
+	query, err := opts.query()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	path := API_USERS_PATH + "?" + query.Encode()
+	resp, err := m.send(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := m.statusError(resp, path); err != nil {
+		return nil, 0, err
+	}
+
+	err = m.decode(resp, path, &page)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range page.Users {
+		m.absoluteSubscriptionURL(&page.Users[i])
+	}
+
+	return page.Users, page.Total, nil
+}
+
+// ListAllMarzbanUsers walks every page of ListMarzbanUsers.
+func (m *marzban) ListAllMarzbanUsers() ([]User, error) {
+	return m.ListAllMarzbanUsersCtx(context.Background())
+}
+
+func (m *marzban) ListAllMarzbanUsersCtx(ctx context.Context) ([]User, error) {
+	return m.ListAllMarzbanUsersWithOptionsCtx(ctx, ListUsersOptions{})
+}
+
+// ListAllMarzbanUsersWithOptions walks every page of the users matching
+// opts; opts.Offset and opts.Limit are ignored.
+func (m *marzban) ListAllMarzbanUsersWithOptions(opts ListUsersOptions) ([]User, error) {
+	return m.ListAllMarzbanUsersWithOptionsCtx(context.Background(), opts)
+}
+
+func (m *marzban) ListAllMarzbanUsersWithOptionsCtx(ctx context.Context, opts ListUsersOptions) ([]User, error) {
+	var users []User
+	err := m.eachUserPage(ctx, opts, func(page []User) error {
+		users = append(users, page...)
+		return nil
+	})
+	return users, err
+}
+
+// eachUserPage calls fn with every page of LIST_PAGE_SIZE users matching
+// opts, stopping at the first error.
+func (m *marzban) eachUserPage(ctx context.Context, opts ListUsersOptions, fn func([]User) error) error {
+	opts.Limit = LIST_PAGE_SIZE
+	for seen := 0; ; {
+		opts.Offset = seen
+		page, total, err := m.ListMarzbanUsersWithOptionsCtx(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		err = fn(page)
+		if err != nil {
+			return err
+		}
+
+		seen += len(page)
+		if seen >= total {
+			return nil
+		}
+	}
+}
+
+// GetSubscription re-fetches the subscription URL and connection links of an
+// existing user, e.g. to resend them without recreating the account.
+func (m *marzban) GetSubscription(username string) (Subscription, error) {
+	return m.GetSubscriptionCtx(context.Background(), username)
+}
+
+func (m *marzban) GetSubscriptionCtx(ctx context.Context, username string) (Subscription, error) {
+	user, err := m.GetMarzbanUserCtx(ctx, username)
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	return Subscription{
+		URL:   user.SubscriptionURL,
+		Links: user.Links,
+	}, nil
+}
+
+// RevokeSubscription rotates the user's credentials so leaked links stop
+// working. The returned Response carries the new subscription URL and links.
+func (m *marzban) RevokeSubscription(username string) (Response, error) {
+	return m.RevokeSubscriptionCtx(context.Background(), username)
+}
+
+func (m *marzban) RevokeSubscriptionCtx(ctx context.Context, username string) (Response, error) {
+	var response Response
+
+	path := userPath(username) + "/revoke_sub"
+	resp, err := m.send(ctx, "POST", path, nil)
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+
+	if err := m.userStatusError(resp, path, username); err != nil {
+		return response, err
+	}
+
+	err = m.decode(resp, path, &response)
+	m.absoluteSubscriptionURL(&response)
+	return response, err
+}
+
+// absoluteSubscriptionURL resolves the relative subscription_url ("/sub/...")
+// the panel returns when XRAY_SUBSCRIPTION_URL_PREFIX is unset against
+// BaseURL, so callers always get a link users can open.
+func (m *marzban) absoluteSubscriptionURL(user *Response) {
+	if strings.HasPrefix(user.SubscriptionURL, "/") {
+		user.SubscriptionURL = m.config.BaseURL + user.SubscriptionURL
+	}
+}
+
+// do sends payload (if any) as JSON and decodes a 2xx answer into out (if
+// any). Non-2xx answers become an *APIError.
+func (m *marzban) do(ctx context.Context, method, path string, payload any, out any) error {
+	var data []byte
+	if payload != nil {
+		var err error
+		data, err = json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := m.send(ctx, method, path, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := m.statusError(resp, path); err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return m.decode(resp, path, out)
+}
+
+// decode unmarshals the body of resp into out. A body that does not parse
+// is logged at debug level and returned, truncated, in a *DecodeError.
+func (m *marzban) decode(resp *http.Response, path string, out any) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		m.config.Logger.Debug("unparsable response", "endpoint", m.url(path), "status", resp.StatusCode, "body", string(body))
+		if len(body) > MAX_DECODE_ERROR_BODY {
+			body = body[:MAX_DECODE_ERROR_BODY]
+		}
+		return &DecodeError{Endpoint: m.url(path), Body: string(body), Err: err}
+	}
+
+	return nil
+}
+
+func userPath(username string) string {
+	return API_USER_PATH + "/" + url.PathEscape(username)
+}
+
+// statusError returns an *APIError for any non-2xx response.
+func (m *marzban) statusError(resp *http.Response, path string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, MAX_ERROR_BODY))
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		Endpoint:   m.url(path),
+	}
+}
+
+// userStatusError is statusError with 404 reported as ErrUserNotFound.
+func (m *marzban) userStatusError(resp *http.Response, path, username string) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s: %w", ErrUserNotFound, username, m.statusError(resp, path))
+	}
+
+	return m.statusError(resp, path)
+}
+
+// userNotFound reports a 404 from a user endpoint as ErrUserNotFound.
+func userNotFound(err error, username string) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s: %w", ErrUserNotFound, username, err)
+	}
+
+	return err
+}
+
+// send issues an authenticated request. When the panel rejects the cached
+// token it logs in again and retries once, returning ErrUnauthorized if the
+// fresh token is rejected as well.
+func (m *marzban) send(ctx context.Context, method, path string, data []byte) (*http.Response, error) {
+	if m.closed.Load() {
+		return nil, ErrClientClosed
+	}
+
+	resp, err := m.sendWithRetry(ctx, method, path, data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	m.InvalidateToken()
+
+	resp, err = m.sendWithRetry(ctx, method, path, data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("%w: %w", ErrUnauthorized, m.statusError(resp, path))
+	}
+
+	return resp, nil
+}
+
+// sendWithRetry retries sendOnce with exponential backoff, up to MaxRetries
+// times, on transport errors and 502/503/504 responses. Other statuses, such
+// as 409, and errors such as ErrUnauthorized are returned as is. Requests
+// that are not idempotent, such as creating a user, are only repeated after
+// a transport error when they never reached the panel.
+func (m *marzban) sendWithRetry(ctx context.Context, method, path string, data []byte) (*http.Response, error) {
+	// Logging in does its own retries.
+	token, err := m.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.retry(ctx, idempotent(method), func() (*http.Response, error) {
+		return m.sendOnce(ctx, token, method, path, data)
+	})
+}
+
+// retry calls attempt until retryable says its result is final or
+// MaxRetries is used up.
+func (m *marzban) retry(ctx context.Context, idempotent bool, attempt func() (*http.Response, error)) (*http.Response, error) {
+	delay := RETRY_BASE_DELAY
+
+	for n := 0; ; n++ {
+		resp, err := attempt()
+		if n >= m.config.MaxRetries || ctx.Err() != nil || !retryable(resp, err, idempotent) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
 	}
 
-	client := http.Client{}
+	return false
+}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+func retryable(resp *http.Response, err error, idempotent bool) bool {
+	if err != nil {
+		// Only failures to reach the panel can clear up on their own; a
+		// rejected login or an unparsable answer would just fail again.
+		// Anything past dialing, such as a timeout waiting for the answer,
+		// may come after the panel acted on the request.
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		if !idempotent {
+			return false
+		}
+
+		var urlErr *url.Error
+		var netErr net.Error
+		return errors.As(err, &urlErr) || errors.As(err, &netErr)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func (m *marzban) sendOnce(ctx context.Context, token, method, path string, data []byte) (*http.Response, error) {
+	var payload io.Reader
+	if data != nil {
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, m.url(path), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	m.setHeaders(req)
 	req.Header.Set("accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, _ = client.Do(req)
+	err = m.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	if resp == nil {
-		return "", errors.New("nil response")
+	resp, err := m.http.Do(req)
+	if err != nil {
+		m.config.Logger.Error("request failed", "method", method, "endpoint", m.url(path), "error", err)
+		return nil, err
 	}
-	var jsonData Token
+	// Do never returns a nil response without an error.
+	m.config.Logger.Debug("request", "method", method, "endpoint", m.url(path), "status", resp.StatusCode)
+
+	return resp, nil
+}
+
+// setHeaders applies Config.Headers to req. The caller sets the standard
+// headers afterwards so they take precedence.
+func (m *marzban) setHeaders(req *http.Request) {
+	for key, value := range m.config.Headers {
+		if http.CanonicalHeaderKey(key) == "Authorization" {
+			continue
+		}
+		req.Header.Set(key, value)
+	}
+}
+
+func (m *marzban) auth(ctx context.Context) (string, error) {
+	form := url.Values{
+		"grant_type":    {""},
+		"username":      {m.config.Username},
+		"password":      {m.config.Password},
+		"scope":         {""},
+		"client_id":     {""},
+		"client_secret": {""},
+	}
+	// Logging in has no side effects, so it is retried like a GET; right
+	// after an install the panel answers 502/503 for a while.
+	resp, err := m.retry(ctx, true, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", m.url(API_AUTH_PATH), strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+
+		m.setHeaders(req)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("accept", "application/json")
+
+		err = m.wait(ctx)
+		if err != nil {
+			return nil, err
+		}
 
-	err = json.NewDecoder(resp.Body).Decode(&jsonData)
+		resp, err := m.http.Do(req)
+		if err != nil {
+			m.config.Logger.Error("login failed", "endpoint", m.url(API_AUTH_PATH), "error", err)
+			return nil, err
+		}
+
+		return resp, nil
+	})
 	if err != nil {
 		return "", err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Println(err)
+			m.config.Logger.Warn("closing response body", "error", err)
 		}
 	}(resp.Body)
 
-	return jsonData.AccessToen, nil
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", m.loginError(resp)
+	}
+
+	var token Token
+	err = m.decode(resp, API_AUTH_PATH, &token)
+	if err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("%w: %s returned no access token", ErrUnauthorized, m.url(API_AUTH_PATH))
+	}
+
+	return token.AccessToken, nil
 }
 
-func CreateTime(month string) int64 {
-	now := time.Now()
-	var futureDate time.Time
+// loginError reports a failed login. A 401, or a 400 or 422 explaining
+// what is wrong with the credentials, is ErrUnauthorized; anything else,
+// such as a 503 from a panel that is still starting, is an *APIError so it
+// is not mistaken for a bad password.
+func (m *marzban) loginError(resp *http.Response) error {
+	err := m.statusError(resp, API_AUTH_PATH)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
 
-	if month == "1" {
-		futureDate = now.AddDate(0, 1, 0)
+	// Rejected logins carry a "detail" message instead of a token.
+	var body struct {
+		Detail json.RawMessage `json:"detail"`
 	}
-	if month == "2" {
-		futureDate = now.AddDate(0, 2, 0)
+	detail := ""
+	if json.Unmarshal([]byte(apiErr.Body), &body) == nil && len(body.Detail) > 0 {
+		if json.Unmarshal(body.Detail, &detail) != nil {
+			detail = string(body.Detail)
+		}
 	}
-	if month == "3" {
-		futureDate = now.AddDate(0, 3, 0)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		if detail == "" {
+			detail = resp.Status
+		}
+	case (resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity) && detail != "":
+	default:
+		m.config.Logger.Error("login failed", "endpoint", m.url(API_AUTH_PATH), "status", resp.StatusCode)
+		return err
 	}
-	if month == "4" {
-		futureDate = now.AddDate(0, 4, 0)
+
+	m.config.Logger.Error("login rejected", "endpoint", m.url(API_AUTH_PATH), "username", m.config.Username, "detail", detail)
+	return fmt.Errorf("%w: %s", ErrUnauthorized, detail)
+}
+
+// CreateTime returns the expiry timestamp for a subscription of the given
+// number of months. It is kept for callers of the old month-number interface;
+// see ParseExpiry for other durations.
+func CreateTime(month string) (int64, error) {
+	return ParseExpiry(month + "mo")
+}
+
+// ExpiryFromDuration returns the Unix timestamp d from now. A zero duration
+// yields 0, which the panel treats as "never expires".
+func ExpiryFromDuration(d time.Duration) int64 {
+	if d == 0 {
+		return 0
 	}
-	if month == "5" {
-		futureDate = now.AddDate(0, 5, 0)
+
+	return time.Now().Add(d).Unix()
+}
+
+// ParseExpiry turns a human duration such as "30d", "3mo" or "12h" into an
+// expiry timestamp. "0" means no expiry. Units other than "mo", "w" and "d"
+// are handled by time.ParseDuration. Absolute dates accepted by
+// ParseExpiryDate work too.
+func ParseExpiry(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return 0, nil
 	}
-	if month == "6" {
-		futureDate = now.AddDate(0, 6, 0)
+
+	if t, ok := parseExpiryDate(s); ok {
+		return ExpiryAt(t)
 	}
 
-	timestamp := time.Date(futureDate.Year(), futureDate.Month(), futureDate.Day(), 0, 0, 0, 0, time.UTC)
+	for _, unit := range []string{"mo", "w", "d"} {
+		number, ok := strings.CutSuffix(s, unit)
+		if !ok {
+			continue
+		}
 
-	t := timestamppb.New(timestamp).Seconds
-	return t
-}
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid expiry %q", s)
+		}
 
-func GenerateData(dataLimit int) int {
-	if dataLimit == 10 {
-		return DATA_LIMIT_10GB
+		switch unit {
+		case "mo":
+			return ExpiryFromMonths(n), nil
+		case "w":
+			return ExpiryFromDuration(time.Duration(n) * 7 * 24 * time.Hour), nil
+		default:
+			return ExpiryFromDuration(time.Duration(n) * 24 * time.Hour), nil
+		}
 	}
 
-	if dataLimit == 15 {
-		return DATA_LIMIT_15GB
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid expiry %q", s)
 	}
 
-	if dataLimit == 20 {
-		return DATA_LIMIT_20GB
+	return ExpiryFromDuration(d), nil
+}
+
+// ExpiryAt returns the Unix timestamp of t, e.g. to align users to a fixed
+// billing date. t must be in the future.
+func ExpiryAt(t time.Time) (int64, error) {
+	if !t.After(time.Now()) {
+		return 0, fmt.Errorf("expiry %s is not in the future", t.Format(time.RFC3339))
 	}
 
-	if dataLimit == 30 {
-		return DATA_LIMIT_30GB
+	return t.Unix(), nil
+}
+
+// ParseExpiryDate parses an RFC 3339 time such as "2025-07-01T00:00:00Z"
+// or a plain date such as "2025-07-01", which means midnight UTC, and
+// returns it as an expiry timestamp. The date must be in the future.
+func ParseExpiryDate(s string) (int64, error) {
+	t, ok := parseExpiryDate(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid expiry date %q, want RFC 3339 or YYYY-MM-DD", s)
 	}
 
-	if dataLimit == 40 {
-		return DATA_LIMIT_40GB
+	return ExpiryAt(t)
+}
+
+func parseExpiryDate(s string) (time.Time, bool) {
+	for _, layout := range EXPIRY_DATE_LAYOUTS {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
 	}
 
-	if dataLimit == 60 {
-		return DATA_LIMIT_60GB
+	return time.Time{}, false
+}
+
+// ExpiryFromMonths returns the Unix timestamp n calendar months from now, or 0
+// when n is 0.
+func ExpiryFromMonths(n int) int64 {
+	if n == 0 {
+		return 0
 	}
 
-	if dataLimit == 70 {
-		return DATA_LIMIT_70GB
+	return time.Now().AddDate(0, n, 0).Unix()
+}
+
+// BytesFromGB converts a quota in gigabytes (1024^3 bytes) to the byte count
+// the panel expects. Fractional values such as 0.5 are allowed; 0 means
+// unlimited.
+func BytesFromGB(gb float64) int64 {
+	return int64(gb * BYTES_PER_GB)
+}
+
+// dataSizeUnits maps the suffixes ParseDataSize accepts, in lower case, to
+// their size in bytes.
+var dataSizeUnits = map[string]int64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "kib": 1 << 10,
+	"m": 1e6, "mb": 1e6, "mib": 1 << 20,
+	"g": 1e9, "gb": 1e9, "gib": 1 << 30,
+	"t": 1e12, "tb": 1e12, "tib": 1 << 40,
+}
+
+// ParseDataSize turns a size such as "50GB", "500 MiB" or "1.5tb" into
+// bytes. Suffixes are case-insensitive; KB/MB/GB/TB are decimal (1000-based)
+// and KiB/MiB/GiB/TiB binary (1024-based). Note that BytesFromGB and the
+// DATA_LIMIT_* constants count in GiB. A bare number is a byte count. Only
+// plain decimal numbers are accepted, so "NaN", "Inf" and "1e3" are errors.
+func ParseDataSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end < 0 {
+		end = len(s)
 	}
 
-	if dataLimit == 80 {
-		return DATA_LIMIT_80GB
+	number, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, fmt.Errorf("invalid data size %q", s)
+	}
+	unit, ok := dataSizeUnits[strings.ToLower(strings.TrimSpace(s[end:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid data size %q: unknown unit", s)
 	}
 
-	if dataLimit == 90 {
-		return DATA_LIMIT_90GB
+	size := number * float64(unit)
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid data size %q: too large", s)
 	}
 
-	if dataLimit == 50 {
-		return DATA_LIMIT_50GB
+	return int64(size), nil
+}
+
+// GenerateData returns the byte count for one of DATA_LIMIT_PRESETS. Any
+// other value is an error rather than 0, which the panel reads as unlimited.
+func GenerateData(dataLimit int) (int, error) {
+	if slices.Contains(DATA_LIMIT_PRESETS, dataLimit) {
+		return int(BytesFromGB(float64(dataLimit))), nil
 	}
 
-	if dataLimit == 100 {
-		return DATA_LIMIT_100GB
+	return 0, fmt.Errorf("unsupported data limit %dGB", dataLimit)
+}
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_@.-]+$`)
+
+// ValidateUsername applies the panel's username rules locally: between
+// USERNAME_MIN_LENGTH and USERNAME_MAX_LENGTH characters drawn from letters,
+// digits and "_@.-". It lets callers reject bad input before a round-trip
+// ends in an opaque 422.
+func ValidateUsername(name string) error {
+	if name == "" {
+		return errors.New("username is empty")
+	}
+	if len(name) < USERNAME_MIN_LENGTH || len(name) > USERNAME_MAX_LENGTH {
+		return fmt.Errorf("username %q must be %d to %d characters long", name, USERNAME_MIN_LENGTH, USERNAME_MAX_LENGTH)
+	}
+	if !usernamePattern.MatchString(name) {
+		return fmt.Errorf("username %q may only contain letters, digits and _@.-", name)
 	}
 
-	return 0
+	return nil
 }
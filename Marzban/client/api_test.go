@@ -0,0 +1,683 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testUsername = "operator"
+	testPassword = "s3cret"
+	testToken    = "test-token"
+)
+
+// testPanel is a fake Marzban panel. It answers API_AUTH_PATH itself and
+// hands every other request to handler.
+type testPanel struct {
+	*httptest.Server
+	logins atomic.Int32
+}
+
+func newTestPanel(t *testing.T, handler http.HandlerFunc) *testPanel {
+	t.Helper()
+
+	panel := &testPanel{}
+	panel.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != API_AUTH_PATH {
+			handler(w, r)
+			return
+		}
+
+		panel.logins.Add(1)
+		if r.Method != "POST" {
+			t.Errorf("login method = %s, want POST", r.Method)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+			t.Errorf("login Content-Type = %q", got)
+		}
+		if r.FormValue("username") != testUsername || r.FormValue("password") != testPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"detail":"Incorrect username or password"}`)
+			return
+		}
+		writeJSON(t, w, Token{AccessToken: testToken, TokenType: "bearer"})
+	}))
+	t.Cleanup(panel.Close)
+
+	return panel
+}
+
+// client returns a client logged in as testUsername. Retries are disabled
+// so tests see every failure as it happens.
+func (p *testPanel) client(opts ...Option) Marzban {
+	cfg := Config{
+		BaseURL:    p.URL,
+		Username:   testUsername,
+		Password:   testPassword,
+		MaxRetries: -1,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return NewMarzbanClientWithConfig(cfg)
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		t.Errorf("encode response: %v", err)
+	}
+}
+
+// userResponse is what the fake panel answers to a create for username.
+func userResponse(username string) Response {
+	return Response{
+		Username:        username,
+		Status:          USER_STATUS_ACTIVE,
+		Links:           []string{"vless://uuid@example.com:443?security=tls#" + username},
+		SubscriptionURL: "/sub/" + username + "-token",
+	}
+}
+
+func TestCreateMarzbanUser(t *testing.T) {
+	var body createUserBody
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != API_USER_PATH {
+			t.Errorf("request = %s %s, want POST %s", r.Method, r.URL.Path, API_USER_PATH)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer "+testToken {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if got := r.Header.Get("Accept"); got != "application/json" {
+			t.Errorf("Accept = %q", got)
+		}
+
+		data, err := io.ReadAll(r.Body)
+		if err != nil || !json.Valid(data) {
+			t.Errorf("body is not valid JSON: %s (%v)", data, err)
+			return
+		}
+		err = json.Unmarshal(data, &body)
+		if err != nil {
+			t.Errorf("decode body: %v", err)
+			return
+		}
+
+		writeJSON(t, w, userResponse(body.Username))
+	})
+
+	expire := time.Now().Add(30 * 24 * time.Hour).Unix()
+	resp, err := panel.client().CreateMarzbanUser(CreateUserRequest{
+		Username:  "alice",
+		DataLimit: DATA_LIMIT_50GB,
+		Expire:    expire,
+		Note:      "order 42",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Username != "alice" || body.DataLimit != DATA_LIMIT_50GB || body.Expire != expire || body.Note != "order 42" {
+		t.Errorf("body = %+v", body)
+	}
+	if body.Status != USER_STATUS_ACTIVE || body.DataLimitResetStrategy != RESET_STRATEGY_NO_RESET {
+		t.Errorf("body defaults = %q, %q", body.Status, body.DataLimitResetStrategy)
+	}
+	if _, ok := body.Proxies[PROTOCOL_VLESS]; !ok || len(body.Proxies) != 1 {
+		t.Errorf("body proxies = %v, want only vless", body.Proxies)
+	}
+
+	if resp.Username != "alice" || len(resp.Links) != 1 {
+		t.Errorf("response = %+v", resp)
+	}
+	if want := panel.URL + "/sub/alice-token"; resp.SubscriptionURL != want {
+		t.Errorf("SubscriptionURL = %q, want %q", resp.SubscriptionURL, want)
+	}
+}
+
+func TestCreateMarzbanUserReusesToken(t *testing.T) {
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, userResponse("alice"))
+	})
+
+	m := panel.client()
+	for range 3 {
+		_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := panel.logins.Load(); got != 1 {
+		t.Errorf("logins = %d, want 1", got)
+	}
+}
+
+func TestCreateMarzbanUserRejectedLogin(t *testing.T) {
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s %s without a token", r.Method, r.URL.Path)
+	})
+
+	_, err := panel.client(WithCredentials(testUsername, "wrong")).CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCreateMarzbanUserAPIError(t *testing.T) {
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, `{"detail":"User already exists"}`)
+	})
+
+	_, err := panel.client().CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("StatusCode = %d, want 409", apiErr.StatusCode)
+	}
+}
+
+// TestCreateMarzbanUserConcurrent shares one client across goroutines; run
+// it with -race to check the token cache locking.
+func TestCreateMarzbanUserConcurrent(t *testing.T) {
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		var body createUserBody
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		writeJSON(t, w, userResponse(body.Username))
+	})
+
+	m := panel.client()
+	const workers = 32
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			username := fmt.Sprintf("user%02d", i)
+			resp, err := m.CreateMarzbanUser(CreateUserRequest{Username: username})
+			if err == nil && resp.Username != username {
+				err = fmt.Errorf("created %q, want %q", resp.Username, username)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if got := panel.logins.Load(); got != 1 {
+		t.Errorf("logins = %d, want 1", got)
+	}
+}
+
+func TestInvalidateTokenConcurrent(t *testing.T) {
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, userResponse("alice"))
+	})
+
+	m := panel.client()
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			m.InvalidateToken()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestResetUserDataUsage(t *testing.T) {
+	var method, path string
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		if got := r.Header.Get("Authorization"); got != "Bearer "+testToken {
+			t.Errorf("Authorization = %q", got)
+		}
+		writeJSON(t, w, userResponse("alice"))
+	})
+
+	err := panel.client().ResetUserDataUsage("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := API_USER_PATH + "/alice/reset"; method != "POST" || path != want {
+		t.Errorf("request = %s %s, want POST %s", method, path, want)
+	}
+}
+
+func TestResetUserDataUsageNotFound(t *testing.T) {
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"detail":"User not found"}`)
+	})
+
+	err := panel.client().ResetUserDataUsage("ghost")
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestBytesFromGB(t *testing.T) {
+	tests := []struct {
+		gb   float64
+		want int64
+	}{
+		{25, 25 * BYTES_PER_GB},
+		{0, 0},
+		{0.5, BYTES_PER_GB / 2},
+		{1.25, 5 * BYTES_PER_GB / 4},
+		{50, DATA_LIMIT_50GB},
+	}
+	for _, tt := range tests {
+		if got := BytesFromGB(tt.gb); got != tt.want {
+			t.Errorf("BytesFromGB(%v) = %d, want %d", tt.gb, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateData(t *testing.T) {
+	for _, gb := range DATA_LIMIT_PRESETS {
+		got, err := GenerateData(gb)
+		if err != nil {
+			t.Errorf("GenerateData(%d): %v", gb, err)
+		}
+		if want := int(BytesFromGB(float64(gb))); got != want {
+			t.Errorf("GenerateData(%d) = %d, want %d", gb, got, want)
+		}
+	}
+
+	// Anything off the table used to come back as 0, an unlimited user.
+	for _, gb := range []int{0, 25, -10} {
+		got, err := GenerateData(gb)
+		if err == nil {
+			t.Errorf("GenerateData(%d) = %d, want an error", gb, got)
+		}
+	}
+}
+
+func TestCreateMarzbanUserFractionalQuota(t *testing.T) {
+	var body createUserBody
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		writeJSON(t, w, userResponse(body.Username))
+	})
+
+	_, err := panel.client().CreateMarzbanUser(CreateUserRequest{
+		Username:  "alice",
+		DataLimit: DataLimit(BytesFromGB(25.5)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(25.5 * BYTES_PER_GB); body.DataLimit != want {
+		t.Errorf("data_limit = %d, want %d", body.DataLimit, want)
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	got, err := ParseExpiry("0")
+	if err != nil || got != 0 {
+		t.Errorf(`ParseExpiry("0") = %d, %v; want 0 (no expiry)`, got, err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"30d", now.Add(30 * 24 * time.Hour)},
+		{"2w", now.Add(14 * 24 * time.Hour)},
+		{"3mo", now.AddDate(0, 3, 0)},
+		{"12h", now.Add(12 * time.Hour)},
+		{" 90m ", now.Add(90 * time.Minute)},
+	}
+	for _, tt := range tests {
+		got, err := ParseExpiry(tt.in)
+		if err != nil {
+			t.Errorf("ParseExpiry(%q): %v", tt.in, err)
+			continue
+		}
+		if diff := got - tt.want.Unix(); diff < -1 || diff > 1 {
+			t.Errorf("ParseExpiry(%q) = %d, want about %d", tt.in, got, tt.want.Unix())
+		}
+	}
+
+	for _, in := range []string{"", "abc", "7", "-3d", "1.5mo", "3 months", "-1h", "2000-01-01"} {
+		got, err := ParseExpiry(in)
+		if err == nil {
+			t.Errorf("ParseExpiry(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestCreateTime(t *testing.T) {
+	for _, month := range []string{"1", "6", "12"} {
+		n, _ := strconv.Atoi(month)
+		got, err := CreateTime(month)
+		if err != nil {
+			t.Errorf("CreateTime(%q): %v", month, err)
+			continue
+		}
+		if want := time.Now().AddDate(0, n, 0).Unix(); got < want-1 || got > want+1 {
+			t.Errorf("CreateTime(%q) = %d, want about %d", month, got, want)
+		}
+	}
+
+	// It used to return 0, an account that never expires, for these.
+	for _, month := range []string{"", "x", "-1"} {
+		got, err := CreateTime(month)
+		if err == nil {
+			t.Errorf("CreateTime(%q) = %d, want an error", month, got)
+		}
+	}
+}
+
+func TestSetUserStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(Marzban) error
+		want string
+	}{
+		{"disable", func(m Marzban) error { return m.DisableUser("alice") }, USER_STATUS_DISABLED},
+		{"enable", func(m Marzban) error { return m.EnableUser("alice") }, USER_STATUS_ACTIVE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]any
+			panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+				if want := API_USER_PATH + "/alice"; r.Method != "PUT" || r.URL.Path != want {
+					t.Errorf("request = %s %s, want PUT %s", r.Method, r.URL.Path, want)
+				}
+				err := json.NewDecoder(r.Body).Decode(&body)
+				if err != nil {
+					t.Errorf("decode body: %v", err)
+				}
+				writeJSON(t, w, userResponse("alice"))
+			})
+
+			err := tt.call(panel.client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Only the status may change; the rest of the user is kept.
+			if len(body) != 1 || body["status"] != tt.want {
+				t.Errorf("body = %v, want only status %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataLimitUnlimited(t *testing.T) {
+	if !Unlimited.IsUnlimited() {
+		t.Error("Unlimited.IsUnlimited() = false")
+	}
+	if DataLimit(BytesFromGB(25)).IsUnlimited() {
+		t.Error("a 25GB quota reads as unlimited")
+	}
+
+	// A typo must not turn into the same 0 as Unlimited.
+	_, err := GenerateData(25)
+	if err == nil {
+		t.Error("GenerateData(25) accepted a value off the preset table")
+	}
+}
+
+func TestCreateMarzbanUserUnlimited(t *testing.T) {
+	var body map[string]any
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		writeJSON(t, w, userResponse("alice"))
+	})
+
+	_, err := panel.client().CreateMarzbanUser(CreateUserRequest{Username: "alice", DataLimit: Unlimited})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit, ok := body["data_limit"]; !ok || limit != float64(0) {
+		t.Errorf("data_limit = %v, want an explicit 0", limit)
+	}
+
+	_, err = panel.client().CreateMarzbanUser(CreateUserRequest{Username: "alice", DataLimit: -1})
+	if err == nil {
+		t.Error("a negative data limit was accepted")
+	}
+}
+
+func TestCreateMarzbanUserOnHoldFields(t *testing.T) {
+	var body map[string]any
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		writeJSON(t, w, userResponse("alice"))
+	})
+	m := panel.client()
+
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key := range body {
+		if strings.HasPrefix(key, "on_hold") {
+			t.Errorf("active user body has %s = %v", key, body[key])
+		}
+	}
+
+	_, err = m.CreateMarzbanUser(CreateUserRequest{
+		Username:             "alice",
+		Status:               USER_STATUS_ON_HOLD,
+		OnHoldExpireDuration: 7 * 24 * 60 * 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["status"] != USER_STATUS_ON_HOLD || body["on_hold_expire_duration"] != float64(7*24*60*60) {
+		t.Errorf("on-hold body = %v", body)
+	}
+	if _, ok := body["on_hold_timeout"]; ok {
+		t.Errorf("on-hold body has on_hold_timeout = %v", body["on_hold_timeout"])
+	}
+}
+
+func TestParseDataSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"50GB", 50e9},
+		{"50gb", 50e9},
+		{"50Gb", 50e9},
+		{"50GiB", 50 << 30},
+		{"50gib", 50 << 30},
+		{"500 MB", 500e6},
+		{"500 MiB", 500 << 20},
+		{"1.5tb", 1.5e12},
+		{"1.5TiB", 3 << 39},
+		{"2k", 2000},
+		{"2KiB", 2048},
+		{"1024", 1024},
+		{"7b", 7},
+		{"0", 0},
+		{" 0.5 G ", 5e8},
+	}
+	for _, tt := range tests {
+		got, err := ParseDataSize(tt.in)
+		if err != nil {
+			t.Errorf("ParseDataSize(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDataSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	invalid := []string{
+		"", "GB", "-5GB", "50XB", "50 G B", "1.2.3GB", ".GB",
+		"NaN", "nan", "Inf", "+Inf", "1e3", "1e3GB", "0x10",
+		"99999999999TB",
+	}
+	for _, in := range invalid {
+		got, err := ParseDataSize(in)
+		if err == nil {
+			t.Errorf("ParseDataSize(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+// proxyPanel serves a user with vless flow and vmess settings and records
+// the proxies of the update that follows.
+func proxyPanel(t *testing.T, updated *map[string]ProxySettings) *testPanel {
+	return newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		user := userResponse("alice")
+		switch r.Method {
+		case "GET":
+			user.Proxies = map[string]ProxySettings{
+				PROTOCOL_VLESS: {ID: "vless-id", Flow: FLOW_XTLS_RPRX_VISION},
+				PROTOCOL_VMESS: {ID: "vmess-id"},
+			}
+		case "PUT":
+			var body UpdateUserRequest
+			err := json.NewDecoder(r.Body).Decode(&body)
+			if err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			*updated = body.Proxies
+		}
+		writeJSON(t, w, user)
+	})
+}
+
+func TestSetUserProxySettings(t *testing.T) {
+	var proxies map[string]ProxySettings
+	panel := proxyPanel(t, &proxies)
+
+	err := panel.client().SetUserProxySettings("alice", map[string]ProxySettings{
+		PROTOCOL_VLESS:  {Flow: "xtls-rprx-vision-udp443"},
+		PROTOCOL_TROJAN: {Password: "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]ProxySettings{
+		PROTOCOL_VLESS:  {ID: "vless-id", Flow: "xtls-rprx-vision-udp443"},
+		PROTOCOL_VMESS:  {ID: "vmess-id"},
+		PROTOCOL_TROJAN: {Password: "secret"},
+	}
+	if !maps.Equal(proxies, want) {
+		t.Errorf("proxies = %v, want %v", proxies, want)
+	}
+}
+
+func TestPatchUserProxySettingsClearsFlow(t *testing.T) {
+	var proxies map[string]ProxySettings
+	panel := proxyPanel(t, &proxies)
+
+	empty := ""
+	err := panel.client().PatchUserProxySettings("alice", map[string]ProxySettingsPatch{
+		PROTOCOL_VLESS: {Flow: &empty},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]ProxySettings{
+		PROTOCOL_VLESS: {ID: "vless-id"},
+		PROTOCOL_VMESS: {ID: "vmess-id"},
+	}
+	if !maps.Equal(proxies, want) {
+		t.Errorf("proxies = %v, want %v", proxies, want)
+	}
+}
+
+func TestLoginErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		unauthorized bool
+	}{
+		{"bad password", http.StatusUnauthorized, `{"detail":"Incorrect username or password"}`, true},
+		{"401 without body", http.StatusUnauthorized, "", true},
+		{"validation", http.StatusUnprocessableEntity, `{"detail":[{"msg":"field required"}]}`, true},
+		{"starting", http.StatusServiceUnavailable, `{"detail":"Service Unavailable"}`, false},
+		{"rate limited", http.StatusTooManyRequests, `{"detail":"slow down"}`, false},
+		{"proxy", http.StatusBadGateway, "<html>502 Bad Gateway</html>", false},
+		{"400 without detail", http.StatusBadRequest, "bad request", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			panel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != API_AUTH_PATH {
+					t.Errorf("unexpected %s %s without a token", r.Method, r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer panel.Close()
+
+			m := NewMarzbanClientWithConfig(Config{
+				BaseURL:    panel.URL,
+				Username:   testUsername,
+				Password:   testPassword,
+				MaxRetries: -1,
+			})
+			err := m.Ping()
+			if got := errors.Is(err, ErrUnauthorized); got != tt.unauthorized {
+				t.Fatalf("err = %v, ErrUnauthorized = %v, want %v", err, got, tt.unauthorized)
+			}
+
+			var apiErr *APIError
+			if !tt.unauthorized && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status) {
+				t.Errorf("err = %v, want a %d APIError", err, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// CreateResult reports the outcome of one user in CreateMarzbanUsers.
+type CreateResult struct {
+	Username string
+	Response Response
+	Err      error
+}
+
+func (m *marzban) CreateMarzbanUsers(reqs []CreateUserRequest) ([]CreateResult, error) {
+	return m.CreateMarzbanUsersCtx(context.Background(), reqs)
+}
+
+// CreateMarzbanUsersCtx creates reqs with up to BULK_CONCURRENCY requests in
+// flight, sharing one token. The returned error is only set when logging in
+// fails; per-user failures are reported in the matching CreateResult, which
+// follow the order of reqs.
+func (m *marzban) CreateMarzbanUsersCtx(ctx context.Context, reqs []CreateUserRequest) ([]CreateResult, error) {
+	_, err := m.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]CreateResult, len(reqs))
+	sem := make(chan struct{}, BULK_CONCURRENCY)
+	var wg sync.WaitGroup
+
+	for i, req := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resp, err := m.CreateMarzbanUserCtx(ctx, req)
+			results[i] = CreateResult{Username: req.Username, Response: resp, Err: err}
+		}()
+	}
+
+	wg.Wait()
+	return results, nil
+}
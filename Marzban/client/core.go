@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// coreStats is the answer of GET /api/core.
+type coreStats struct {
+	Version string `json:"version"`
+	Started bool   `json:"started"`
+}
+
+// GetCoreConfig returns the xray config the panel is running with.
+func (m *marzban) GetCoreConfig() (json.RawMessage, error) {
+	return m.GetCoreConfigCtx(context.Background())
+}
+
+func (m *marzban) GetCoreConfigCtx(ctx context.Context) (json.RawMessage, error) {
+	var cfg json.RawMessage
+	err := m.do(ctx, "GET", API_CORE_CONFIG_PATH, nil, &cfg)
+	return cfg, err
+}
+
+// UpdateCoreConfig replaces the panel's xray config through the API instead
+// of rewriting xray_config.json on disk.
+func (m *marzban) UpdateCoreConfig(cfg json.RawMessage) error {
+	return m.UpdateCoreConfigCtx(context.Background(), cfg)
+}
+
+func (m *marzban) UpdateCoreConfigCtx(ctx context.Context, cfg json.RawMessage) error {
+	if !json.Valid(cfg) {
+		return errors.New("core config is not valid JSON")
+	}
+
+	return m.do(ctx, "PUT", API_CORE_CONFIG_PATH, cfg, nil)
+}
+
+// RestartCore restarts xray so a config pushed with UpdateCoreConfig takes
+// effect. It returns ErrCoreRestartFailed if the panel reports an error or
+// the core is not running afterwards.
+func (m *marzban) RestartCore() error {
+	return m.RestartCoreCtx(context.Background())
+}
+
+func (m *marzban) RestartCoreCtx(ctx context.Context) error {
+	err := m.do(ctx, "POST", API_CORE_PATH+"/restart", nil, nil)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode >= 500 {
+		return fmt.Errorf("%w: %w", ErrCoreRestartFailed, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	var stats coreStats
+	err = m.do(ctx, "GET", API_CORE_PATH, nil, &stats)
+	if err != nil {
+		return err
+	}
+	if !stats.Started {
+		return ErrCoreRestartFailed
+	}
+
+	return nil
+}
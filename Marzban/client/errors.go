@@ -0,0 +1,52 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnauthorized is returned when the panel rejects a freshly issued token.
+	ErrUnauthorized = errors.New("marzban: authentication failed")
+	// ErrUserNotFound is returned when the panel has no user with the given name.
+	ErrUserNotFound = errors.New("marzban: user not found")
+	// ErrNodeNotFound is returned when the panel has no node with the given ID.
+	ErrNodeNotFound = errors.New("marzban: node not found")
+	// ErrAdminNotFound is returned when the panel has no admin with the given name.
+	ErrAdminNotFound = errors.New("marzban: admin not found")
+	// ErrCoreRestartFailed is returned when xray does not come back up after
+	// RestartCore.
+	ErrCoreRestartFailed = errors.New("marzban: core failed to restart")
+	// ErrClientClosed is returned by every call made after Close.
+	ErrClientClosed = errors.New("marzban: client is closed")
+)
+
+// APIError is returned when the panel answers with a non-2xx status. Use
+// errors.As to inspect it, e.g. to tell a 409 (user already exists) from a
+// 401 or a 5xx.
+type APIError struct {
+	StatusCode int
+	Body       string
+	Endpoint   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("marzban: %s returned %d: %s", e.Endpoint, e.StatusCode, e.Body)
+}
+
+// DecodeError is returned when a 2xx response is not the JSON the client
+// expects, typically an HTML page from a misconfigured reverse proxy. Body
+// holds the start of what was received.
+type DecodeError struct {
+	Endpoint string
+	Body     string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("marzban: %s returned an unexpected body: %v: %q", e.Endpoint, e.Err, e.Body)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
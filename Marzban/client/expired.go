@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DeleteExpiredUsers deletes every user whose status is expired and returns
+// how many were removed. Users that fail to delete are reported in the
+// error while the rest are still removed. Use DeleteExpiredUsersDryRun to
+// see who would go first.
+func (m *marzban) DeleteExpiredUsers() (int, error) {
+	return m.DeleteExpiredUsersCtx(context.Background())
+}
+
+func (m *marzban) DeleteExpiredUsersCtx(ctx context.Context) (int, error) {
+	usernames, err := m.DeleteExpiredUsersDryRunCtx(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int
+	var errs []error
+	for _, username := range usernames {
+		err := m.DeleteMarzbanUserCtx(ctx, username)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %w", username, err))
+			continue
+		}
+		deleted++
+	}
+
+	m.config.Logger.Info("deleted expired users", "deleted", deleted, "failed", len(errs))
+	return deleted, errors.Join(errs...)
+}
+
+// DeleteExpiredUsersDryRun returns the usernames DeleteExpiredUsers would
+// delete without deleting anything.
+func (m *marzban) DeleteExpiredUsersDryRun() ([]string, error) {
+	return m.DeleteExpiredUsersDryRunCtx(context.Background())
+}
+
+func (m *marzban) DeleteExpiredUsersDryRunCtx(ctx context.Context) ([]string, error) {
+	users, err := m.ListAllMarzbanUsersWithOptionsCtx(ctx, ListUsersOptions{Status: USER_STATUS_EXPIRED})
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := make([]string, 0, len(users))
+	for _, user := range users {
+		usernames = append(usernames, user.Username)
+	}
+
+	return usernames, nil
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ExportFormat selects the encoding of ExportUsers.
+type ExportFormat string
+
+const (
+	// EXPORT_FORMAT_JSON writes a JSON array of full user objects.
+	EXPORT_FORMAT_JSON ExportFormat = "json"
+	// EXPORT_FORMAT_CSV writes one row per user with EXPORT_CSV_HEADER.
+	EXPORT_FORMAT_CSV ExportFormat = "csv"
+)
+
+// EXPORT_CSV_HEADER names the columns of a CSV export.
+var EXPORT_CSV_HEADER = []string{"username", "status", "data_limit", "used_traffic", "expire"}
+
+// ExportUsers writes every user to w for backups and migrations. Users are
+// fetched and written a page at a time, so large panels are never held in
+// memory at once.
+func (m *marzban) ExportUsers(w io.Writer, format ExportFormat) error {
+	return m.ExportUsersCtx(context.Background(), w, format)
+}
+
+func (m *marzban) ExportUsersCtx(ctx context.Context, w io.Writer, format ExportFormat) error {
+	switch format {
+	case EXPORT_FORMAT_JSON:
+		return m.exportJSON(ctx, w)
+	case EXPORT_FORMAT_CSV:
+		return m.exportCSV(ctx, w)
+	}
+
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+func (m *marzban) exportJSON(ctx context.Context, w io.Writer) error {
+	_, err := io.WriteString(w, "[\n")
+	if err != nil {
+		return err
+	}
+
+	first := true
+	err = m.eachUserPage(ctx, ListUsersOptions{}, func(page []User) error {
+		for _, user := range page {
+			data, err := json.Marshal(user)
+			if err != nil {
+				return err
+			}
+			if !first {
+				_, err = io.WriteString(w, ",\n")
+				if err != nil {
+					return err
+				}
+			}
+			first = false
+
+			_, err = w.Write(data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
+
+func (m *marzban) exportCSV(ctx context.Context, w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	err := out.Write(EXPORT_CSV_HEADER)
+	if err != nil {
+		return err
+	}
+
+	err = m.eachUserPage(ctx, ListUsersOptions{}, func(page []User) error {
+		for _, user := range page {
+			err := out.Write([]string{
+				user.Username,
+				user.Status,
+				strconv.FormatInt(user.DataLimit, 10),
+				strconv.FormatInt(user.UsedTraffic, 10),
+				strconv.FormatInt(user.Expire, 10),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		out.Flush()
+		return out.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ImportResult reports the outcome of one record in ImportUsers. Skipped is
+// set, with a nil Err, when the username already exists on the panel.
+type ImportResult struct {
+	Username string
+	Response Response
+	Skipped  bool
+	Err      error
+}
+
+// ImportUsers recreates the users of an ExportUsers dump, e.g. on a new
+// server. Users keep their proxy settings, so existing links stay valid for
+// JSON imports; CSV only carries quota and expiry. Disabled users are
+// disabled again after creation, and users whose expiry has passed fail
+// with the create error. The returned error is only set when r cannot be
+// decoded; per-user failures are reported in the results.
+func (m *marzban) ImportUsers(r io.Reader, format ExportFormat) ([]ImportResult, error) {
+	return m.ImportUsersCtx(context.Background(), r, format)
+}
+
+func (m *marzban) ImportUsersCtx(ctx context.Context, r io.Reader, format ExportFormat) ([]ImportResult, error) {
+	var results []ImportResult
+	importUser := func(user User) error {
+		results = append(results, m.importUser(ctx, user))
+		return ctx.Err()
+	}
+
+	var err error
+	switch format {
+	case EXPORT_FORMAT_JSON:
+		err = decodeJSONUsers(r, importUser)
+	case EXPORT_FORMAT_CSV:
+		err = decodeCSVUsers(r, importUser)
+	default:
+		err = fmt.Errorf("unsupported import format %q", format)
+	}
+
+	return results, err
+}
+
+func (m *marzban) importUser(ctx context.Context, user User) ImportResult {
+	result := ImportResult{Username: user.Username}
+
+	req := CreateUserRequest{
+		Username:               user.Username,
+		DataLimit:              DataLimit(user.DataLimit),
+		Expire:                 user.Expire,
+		Proxies:                user.Proxies,
+		DataLimitResetStrategy: user.DataLimitResetStrategy,
+		Note:                   user.Note,
+	}
+	if user.Status == USER_STATUS_ON_HOLD && user.OnHoldExpireDuration != nil {
+		req.Status = USER_STATUS_ON_HOLD
+		req.OnHoldExpireDuration = int64(*user.OnHoldExpireDuration)
+	}
+
+	result.Response, result.Err = m.CreateMarzbanUserCtx(ctx, req)
+
+	var apiErr *APIError
+	if errors.As(result.Err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		result.Skipped = true
+		result.Err = nil
+		return result
+	}
+	if result.Err == nil && user.Status == USER_STATUS_DISABLED {
+		result.Err = m.DisableUserCtx(ctx, user.Username)
+	}
+
+	return result
+}
+
+// decodeJSONUsers streams the array written by ExportUsers, calling fn with
+// each user as soon as it is read.
+func decodeJSONUsers(r io.Reader, fn func(User) error) error {
+	decoder := json.NewDecoder(r)
+
+	_, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("read import: %w", err)
+	}
+
+	for decoder.More() {
+		var user User
+		err = decoder.Decode(&user)
+		if err != nil {
+			return fmt.Errorf("read import: %w", err)
+		}
+
+		err = fn(user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeCSVUsers reads rows by their EXPORT_CSV_HEADER column names, so
+// reordered or extra columns are tolerated.
+func decodeCSVUsers(r io.Reader, fn func(User) error) error {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("read import: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	if _, ok := columns["username"]; !ok {
+		return errors.New("read import: no username column")
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read import: %w", err)
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return record[i]
+		}
+		number := func(name string) (int64, error) {
+			value := field(name)
+			if value == "" {
+				return 0, nil
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("read import: line %d: invalid %s %q", line, name, value)
+			}
+			return n, nil
+		}
+
+		user := User{
+			Username: field("username"),
+			Status:   field("status"),
+		}
+		user.DataLimit, err = number("data_limit")
+		if err != nil {
+			return err
+		}
+		user.Expire, err = number("expire")
+		if err != nil {
+			return err
+		}
+
+		err = fn(user)
+		if err != nil {
+			return err
+		}
+	}
+}
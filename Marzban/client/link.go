@@ -0,0 +1,171 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ProxyLink is a decoded share link such as vless://, vmess://, trojan://
+// or ss:// as found in Response.Links.
+type ProxyLink struct {
+	Protocol string
+	// ID is the UUID for vless/vmess and the password for trojan and
+	// shadowsocks.
+	ID      string
+	Address string
+	Port    int
+	// Remark is the display name, the URI fragment for most protocols.
+	Remark string
+	// Params holds the remaining settings, e.g. "security", "sni", "type"
+	// or, for shadowsocks, "method".
+	Params map[string]string
+}
+
+// ParseLink decodes a single share link. vmess links, which carry a base64
+// JSON document instead of a URI, are handled specially.
+func ParseLink(uri string) (ProxyLink, error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return ProxyLink{}, fmt.Errorf("invalid link %q: missing scheme", uri)
+	}
+
+	switch scheme {
+	case PROTOCOL_VMESS:
+		return parseVmessLink(rest)
+	case PROTOCOL_VLESS, PROTOCOL_TROJAN, PROTOCOL_SHADOWSOCKS, "ss":
+		return parseURILink(uri)
+	}
+
+	return ProxyLink{}, fmt.Errorf("invalid link: unsupported protocol %q", scheme)
+}
+
+// ParseLinks decodes every link, stopping at the first one that fails.
+func ParseLinks(uris []string) ([]ProxyLink, error) {
+	links := make([]ProxyLink, 0, len(uris))
+	for i, uri := range uris {
+		link, err := ParseLink(uri)
+		if err != nil {
+			return links, fmt.Errorf("link %d: %w", i, err)
+		}
+		links = append(links, link)
+	}
+
+	return links, nil
+}
+
+func parseURILink(uri string) (ProxyLink, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ProxyLink{}, fmt.Errorf("invalid link: %w", err)
+	}
+
+	link := ProxyLink{
+		Protocol: u.Scheme,
+		Address:  u.Hostname(),
+		Remark:   u.Fragment,
+		Params:   make(map[string]string),
+	}
+	if u.User != nil {
+		link.ID = u.User.Username()
+	}
+	for key, values := range u.Query() {
+		link.Params[key] = values[0]
+	}
+
+	if link.Protocol == "ss" {
+		link.Protocol = PROTOCOL_SHADOWSOCKS
+		err = parseShadowsocksUser(&link, u)
+		if err != nil {
+			return ProxyLink{}, err
+		}
+	}
+
+	if u.Port() != "" {
+		link.Port, err = strconv.Atoi(u.Port())
+		if err != nil {
+			return ProxyLink{}, fmt.Errorf("invalid link port %q", u.Port())
+		}
+	}
+
+	return link, nil
+}
+
+// parseShadowsocksUser decodes the "method:password" user info of an ss://
+// link, which is usually base64 encoded.
+func parseShadowsocksUser(link *ProxyLink, u *url.URL) error {
+	userInfo := link.ID
+	if password, ok := u.User.Password(); ok {
+		userInfo += ":" + password
+	} else if decoded, err := decodeBase64(userInfo); err == nil {
+		userInfo = string(decoded)
+	}
+
+	method, password, ok := strings.Cut(userInfo, ":")
+	if !ok {
+		return fmt.Errorf("invalid shadowsocks link: no method in user info")
+	}
+	link.ID = password
+	link.Params["method"] = method
+
+	return nil
+}
+
+func parseVmessLink(encoded string) (ProxyLink, error) {
+	data, err := decodeBase64(encoded)
+	if err != nil {
+		return ProxyLink{}, fmt.Errorf("invalid vmess link: %w", err)
+	}
+
+	var fields map[string]any
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return ProxyLink{}, fmt.Errorf("invalid vmess link: %w", err)
+	}
+
+	link := ProxyLink{
+		Protocol: PROTOCOL_VMESS,
+		Params:   make(map[string]string),
+	}
+	for key, value := range fields {
+		// Clients disagree on whether port and aid are strings or numbers.
+		s := fmt.Sprint(value)
+		switch key {
+		case "add":
+			link.Address = s
+		case "port":
+			link.Port, err = strconv.Atoi(s)
+			if err != nil {
+				return ProxyLink{}, fmt.Errorf("invalid vmess link port %q", s)
+			}
+		case "id":
+			link.ID = s
+		case "ps":
+			link.Remark = s
+		default:
+			link.Params[key] = s
+		}
+	}
+
+	// An IPv6 address may come bracketed as in a URI.
+	if host, _, err := net.SplitHostPort(link.Address + ":0"); err == nil {
+		link.Address = host
+	}
+
+	return link, nil
+}
+
+// decodeBase64 accepts the standard and URL-safe alphabets, with or without
+// padding, since share links use all of them.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+
+	return base64.RawStdEncoding.DecodeString(s)
+}
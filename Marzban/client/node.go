@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Node is a marzban-node server registered with the panel.
+type Node struct {
+	ID               int     `json:"id"`
+	Name             string  `json:"name"`
+	Address          string  `json:"address"`
+	Port             int     `json:"port"`
+	APIPort          int     `json:"api_port"`
+	UsageCoefficient float64 `json:"usage_coefficient"`
+	XrayVersion      *string `json:"xray_version"` // Pointer to handle null
+	Status           string  `json:"status"`
+	Message          *string `json:"message"` // Pointer to handle null
+}
+
+// AddNodeRequest is the JSON payload accepted by POST /api/node. Zero ports
+// and coefficient are filled in by the panel (62050, 62051 and 1).
+type AddNodeRequest struct {
+	Name             string  `json:"name"`
+	Address          string  `json:"address"`
+	Port             int     `json:"port,omitempty"`
+	APIPort          int     `json:"api_port,omitempty"`
+	UsageCoefficient float64 `json:"usage_coefficient,omitempty"`
+	// AddAsNewHost also adds the node's address as a host for every inbound.
+	AddAsNewHost bool `json:"add_as_new_host"`
+}
+
+// NodeSettings carries the client certificate a node must trust to accept
+// connections from this panel.
+type NodeSettings struct {
+	MinNodeVersion string `json:"min_node_version"`
+	Certificate    string `json:"certificate"`
+}
+
+func (m *marzban) ListNodes() ([]Node, error) {
+	return m.ListNodesCtx(context.Background())
+}
+
+func (m *marzban) ListNodesCtx(ctx context.Context) ([]Node, error) {
+	var nodes []Node
+	err := m.do(ctx, "GET", API_NODES_PATH, nil, &nodes)
+	return nodes, err
+}
+
+func (m *marzban) AddNode(req AddNodeRequest) (Node, error) {
+	return m.AddNodeCtx(context.Background(), req)
+}
+
+func (m *marzban) AddNodeCtx(ctx context.Context, req AddNodeRequest) (Node, error) {
+	var node Node
+	err := m.do(ctx, "POST", API_NODE_PATH, req, &node)
+	return node, err
+}
+
+func (m *marzban) RemoveNode(id int) error {
+	return m.RemoveNodeCtx(context.Background(), id)
+}
+
+func (m *marzban) RemoveNodeCtx(ctx context.Context, id int) error {
+	err := m.do(ctx, "DELETE", API_NODE_PATH+"/"+strconv.Itoa(id), nil, nil)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %d: %w", ErrNodeNotFound, id, err)
+	}
+
+	return err
+}
+
+func (m *marzban) GetNodeSettings() (NodeSettings, error) {
+	return m.GetNodeSettingsCtx(context.Background())
+}
+
+func (m *marzban) GetNodeSettingsCtx(ctx context.Context) (NodeSettings, error) {
+	var settings NodeSettings
+	err := m.do(ctx, "GET", API_NODE_PATH+"/settings", nil, &settings)
+	return settings, err
+}
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryFailsTwiceThenSucceeds(t *testing.T) {
+	var calls atomic.Int32
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(t, w, userResponse("alice"))
+	})
+
+	m := panel.client(func(cfg *Config) { cfg.MaxRetries = 2 })
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
+
+func TestRetryDroppedConnection(t *testing.T) {
+	var calls atomic.Int32
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("hijack: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		writeJSON(t, w, userResponse("alice"))
+	})
+
+	m := panel.client(func(cfg *Config) { cfg.MaxRetries = 2 })
+	_, err := m.GetMarzbanUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	m := panel.client(func(cfg *Config) { cfg.MaxRetries = 1 })
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("err = %v, want a 502 APIError", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("calls = %d, want 2", got)
+	}
+}
+
+func TestNoRetryOnConflict(t *testing.T) {
+	var calls atomic.Int32
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusConflict)
+	})
+
+	m := panel.client(func(cfg *Config) { cfg.MaxRetries = 2 })
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestNoRetryOnRejectedLogin(t *testing.T) {
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s %s without a token", r.Method, r.URL.Path)
+	})
+
+	m := panel.client(WithCredentials(testUsername, "wrong"), func(cfg *Config) { cfg.MaxRetries = 2 })
+	start := time.Now()
+	err := m.Ping()
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+	if got := panel.logins.Load(); got != 1 {
+		t.Errorf("logins = %d, want 1", got)
+	}
+	if elapsed := time.Since(start); elapsed >= RETRY_BASE_DELAY {
+		t.Errorf("took %s, want no backoff", elapsed)
+	}
+}
+
+func TestNoRetryOnDecodeError(t *testing.T) {
+	var calls atomic.Int32
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte("<html>proxy error page</html>"))
+	})
+
+	m := panel.client(func(cfg *Config) { cfg.MaxRetries = 2 })
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("err = %v, want *DecodeError", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestRetryLoginUnavailable(t *testing.T) {
+	var logins atomic.Int32
+	panel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != API_AUTH_PATH {
+			writeJSON(t, w, userResponse("alice"))
+			return
+		}
+		if logins.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(t, w, Token{AccessToken: testToken, TokenType: "bearer"})
+	}))
+	defer panel.Close()
+
+	m := NewMarzbanClientWithConfig(Config{
+		BaseURL:    panel.URL,
+		Username:   testUsername,
+		Password:   testPassword,
+		MaxRetries: 2,
+	})
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := logins.Load(); got != 3 {
+		t.Errorf("logins = %d, want 3", got)
+	}
+}
+
+// A create whose answer is late may already have been applied; sending it
+// again would end in a 409 for a user that was created.
+func TestNoRetryOnCreateTimeout(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	defer close(release)
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+	})
+
+	m := panel.client(WithTimeout(100*time.Millisecond), func(cfg *Config) { cfg.MaxRetries = 2 })
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	if err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestRetryableTransportErrors(t *testing.T) {
+	dial := &url.Error{Op: "Post", URL: "http://panel", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+	read := &url.Error{Op: "Post", URL: "http://panel", Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}
+
+	tests := []struct {
+		name       string
+		err        error
+		idempotent bool
+		want       bool
+	}{
+		{"refused GET", dial, true, true},
+		{"refused POST", dial, false, true},
+		{"reset GET", read, true, true},
+		{"reset POST", read, false, false},
+		{"timeout POST", &url.Error{Op: "Post", URL: "http://panel", Err: context.DeadlineExceeded}, false, false},
+		{"rejected login", ErrUnauthorized, true, false},
+	}
+	for _, tt := range tests {
+		if got := retryable(nil, tt.err, tt.idempotent); got != tt.want {
+			t.Errorf("%s: retryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
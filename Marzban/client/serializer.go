@@ -1,49 +1,168 @@
 package client
 
-type Response struct {
-	Proxies                Proxies          `json:"proxies"`
-	Expire                 int64            `json:"expire"`
-	DataLimit              int64            `json:"data_limit"` // Pointer to handle null
-	DataLimitResetStrategy string           `json:"data_limit_reset_strategy"`
-	Inbounds               Inbounds         `json:"inbounds"`
-	Note                   string           `json:"note"`
-	SubUpdatedAt           *string          `json:"sub_updated_at"`          // Pointer to handle null
-	SubLastUserAgent       *string          `json:"sub_last_user_agent"`     // Pointer to handle null
-	OnlineAt               *string          `json:"online_at"`               // Pointer to handle null
-	OnHoldExpireDuration   *int             `json:"on_hold_expire_duration"` // Pointer to handle null
-	OnHoldTimeout          string           `json:"on_hold_timeout"`
-	AutoDeleteInDays       *int             `json:"auto_delete_in_days"` // Pointer to handle null
-	Username               string           `json:"username"`
-	Status                 string           `json:"status"`
-	UsedTraffic            int64            `json:"used_traffic"`
-	LifetimeUsedTraffic    int64            `json:"lifetime_used_traffic"`
-	CreatedAt              string           `json:"created_at"`
-	Links                  []string         `json:"links"`
-	SubscriptionURL        string           `json:"subscription_url"`
-	ExcludedInbounds       ExcludedInbounds `json:"excluded_inbounds"`
-	Admin                  Admin            `json:"admin"`
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// CreateUserRequest describes a user to create. DataLimit is in bytes (see
+// BytesFromGB); Unlimited (0) means no quota. Expire is a Unix timestamp
+// (see ParseExpiry); 0 means the user never expires.
+type CreateUserRequest struct {
+	Username  string
+	DataLimit DataLimit
+	Expire    int64
+	// Proxies selects the protocols to provision, keyed by one of PROTOCOLS.
+	// An empty map provisions vless with panel-generated settings.
+	Proxies map[string]ProxySettings
+	// DataLimitResetStrategy is one of RESET_STRATEGIES; empty means
+	// RESET_STRATEGY_NO_RESET.
+	DataLimitResetStrategy string
+	// Status is USER_STATUS_ACTIVE (the default) or USER_STATUS_ON_HOLD. An
+	// on-hold user's countdown of OnHoldExpireDuration seconds only starts on
+	// first connection; such users must set it and must not set Expire. It
+	// is left out of the request for active users.
+	Status               string
+	OnHoldExpireDuration int64
+	// Note is free-form metadata shown in the panel, e.g. an order ID.
+	Note string
+}
+
+// ProxySettings holds the per-protocol settings of a user. Fields left empty
+// are generated by the panel.
+type ProxySettings struct {
+	ID       string `json:"id,omitempty"`       // vless, vmess
+	Flow     string `json:"flow,omitempty"`     // vless
+	Password string `json:"password,omitempty"` // trojan, shadowsocks
+	Method   string `json:"method,omitempty"`   // shadowsocks
+}
+
+// ProxySettingsPatch changes individual fields of a user's ProxySettings
+// with PatchUserProxySettings. A nil field is left as it is and a pointer to
+// "" clears it, e.g. to drop a vless Flow. A cleared ID or Password is
+// regenerated by the panel, which changes the user's links.
+type ProxySettingsPatch struct {
+	ID       *string
+	Flow     *string
+	Password *string
+	Method   *string
+}
+
+// createUserBody is the JSON payload accepted by POST /api/user.
+type createUserBody struct {
+	Username               string                   `json:"username"`
+	Proxies                map[string]ProxySettings `json:"proxies"`
+	Expire                 int64                    `json:"expire"`
+	DataLimit              int64                    `json:"data_limit"`
+	DataLimitResetStrategy string                   `json:"data_limit_reset_strategy"`
+	Status                 string                   `json:"status"`
+	Note                   string                   `json:"note"`
+	OnHoldExpireDuration   int64                    `json:"on_hold_expire_duration,omitempty"`
 }
 
-type Proxies struct {
-	VLESS Vless `json:"vless"`
+// UpdateUserRequest is the JSON payload accepted by PUT /api/user/{username}.
+// Nil fields are omitted so the panel keeps their current values.
+type UpdateUserRequest struct {
+	Proxies                map[string]ProxySettings `json:"proxies,omitempty"`
+	Expire                 *int64                   `json:"expire,omitempty"`
+	DataLimit              *int64                   `json:"data_limit,omitempty"`
+	DataLimitResetStrategy *string                  `json:"data_limit_reset_strategy,omitempty"`
+	Status                 *string                  `json:"status,omitempty"`
+	Note                   *string                  `json:"note,omitempty"`
 }
 
-type Vless struct {
-	ID   string `json:"id"`
-	Flow string `json:"flow"`
+// Subscription is what an end user needs to connect: the subscription URL
+// and the individual proxy links behind it.
+type Subscription struct {
+	URL   string
+	Links []string
 }
 
-type Inbounds struct {
-	VLESS []string `json:"vless"`
+// ListUsersOptions selects and orders the users returned by
+// ListMarzbanUsersWithOptions. Zero fields are not sent.
+type ListUsersOptions struct {
+	Offset int
+	Limit  int
+	// Status is one of USER_STATUSES.
+	Status string
+	// Search matches part of the username or note.
+	Search string
+	// SortBy is a field such as "username", "created_at", "used_traffic"
+	// or "expire"; prefix it with "-" for descending order.
+	SortBy string
 }
 
-type ExcludedInbounds struct {
-	VLESS []string `json:"vless"`
+func (o ListUsersOptions) query() (url.Values, error) {
+	query := url.Values{}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Status != "" {
+		if !slices.Contains(USER_STATUSES, o.Status) {
+			return nil, fmt.Errorf("unknown user status %q", o.Status)
+		}
+		query.Set("status", o.Status)
+	}
+	if o.Search != "" {
+		query.Set("search", o.Search)
+	}
+	if o.SortBy != "" {
+		query.Set("sort", o.SortBy)
+	}
+
+	return query, nil
+}
+
+// usersResponse is the page returned by GET /api/users.
+type usersResponse struct {
+	Users []User `json:"users"`
+	Total int    `json:"total"`
+}
+
+// User is the full user object returned by GET /api/user/{username}. The
+// panel answers create and get calls with the same schema, so it shares its
+// definition with Response.
+type User = Response
+
+// Response is a user as returned by the panel. SubscriptionURL is the single
+// link that serves all of Links to client apps and is what front-ends
+// usually display; a relative URL from the panel is made absolute with
+// Config.BaseURL.
+type Response struct {
+	Proxies                map[string]ProxySettings `json:"proxies"`
+	Expire                 int64                    `json:"expire"`
+	DataLimit              int64                    `json:"data_limit"` // Pointer to handle null
+	DataLimitResetStrategy string                   `json:"data_limit_reset_strategy"`
+	Inbounds               map[string][]string      `json:"inbounds"`
+	Note                   string                   `json:"note"`
+	SubUpdatedAt           *string                  `json:"sub_updated_at"`          // Pointer to handle null
+	SubLastUserAgent       *string                  `json:"sub_last_user_agent"`     // Pointer to handle null
+	OnlineAt               *string                  `json:"online_at"`               // Pointer to handle null
+	OnHoldExpireDuration   *int                     `json:"on_hold_expire_duration"` // Pointer to handle null
+	OnHoldTimeout          string                   `json:"on_hold_timeout"`
+	AutoDeleteInDays       *int                     `json:"auto_delete_in_days"` // Pointer to handle null
+	Username               string                   `json:"username"`
+	Status                 string                   `json:"status"`
+	UsedTraffic            int64                    `json:"used_traffic"`
+	LifetimeUsedTraffic    int64                    `json:"lifetime_used_traffic"`
+	CreatedAt              string                   `json:"created_at"`
+	Links                  []string                 `json:"links"`
+	SubscriptionURL        string                   `json:"subscription_url"`
+	ExcludedInbounds       map[string][]string      `json:"excluded_inbounds"`
+	Admin                  Admin                    `json:"admin"`
 }
 
 type Admin struct {
 	Username       string  `json:"username"`
 	IsSudo         bool    `json:"is_sudo"`
-	TelegramID     int  `json:"telegram_id"`     // Pointer to handle null
+	TelegramID     int     `json:"telegram_id"`     // Pointer to handle null
 	DiscordWebhook *string `json:"discord_webhook"` // Pointer to handle null
-}
\ No newline at end of file
+	// UsersUsage is the traffic, in bytes, used by the admin's users. Older
+	// panels do not report it.
+	UsersUsage int64 `json:"users_usage"`
+}
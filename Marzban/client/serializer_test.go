@@ -0,0 +1,26 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// ValidateUsername keeps such names away from the panel, but the body itself
+// must stay valid JSON whatever the username holds.
+func TestCreateUserBodyEscapesUsername(t *testing.T) {
+	for _, username := range []string{`al"ice`, `al\ice`, `"}, "status": "disabled`} {
+		data, err := json.Marshal(createUserBody{Username: username, Status: USER_STATUS_ACTIVE})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body createUserBody
+		err = json.Unmarshal(data, &body)
+		if err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		if body.Username != username || body.Status != USER_STATUS_ACTIVE {
+			t.Errorf("round trip of %q = %+v", username, body)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// UserSummary condenses a user's quota and expiry into the figures an
+// account page shows. With Unlimited set RemainingBytes and PercentUsed are
+// 0; with NeverExpires set RemainingDays is 0 and ExpiresAt is zero.
+type UserSummary struct {
+	Username    string
+	Status      string
+	UsedTraffic int64
+
+	Unlimited      bool
+	DataLimit      int64
+	RemainingBytes int64
+	// PercentUsed is in 0-100 and may pass 100 if the panel has not yet
+	// limited the user.
+	PercentUsed float64
+
+	NeverExpires bool
+	ExpiresAt    time.Time
+	// RemainingDays counts whole days left, 0 once expired.
+	RemainingDays int
+}
+
+// GetUserSummary fetches username and derives its remaining quota and days.
+func (m *marzban) GetUserSummary(username string) (UserSummary, error) {
+	return m.GetUserSummaryCtx(context.Background(), username)
+}
+
+func (m *marzban) GetUserSummaryCtx(ctx context.Context, username string) (UserSummary, error) {
+	user, err := m.GetMarzbanUserCtx(ctx, username)
+	if err != nil {
+		return UserSummary{}, err
+	}
+
+	return summarize(user, time.Now()), nil
+}
+
+func summarize(user User, now time.Time) UserSummary {
+	summary := UserSummary{
+		Username:    user.Username,
+		Status:      user.Status,
+		UsedTraffic: user.UsedTraffic,
+		DataLimit:   user.DataLimit,
+		Unlimited:   DataLimit(user.DataLimit).IsUnlimited(),
+	}
+
+	if !summary.Unlimited {
+		summary.RemainingBytes = max(user.DataLimit-user.UsedTraffic, 0)
+		summary.PercentUsed = float64(user.UsedTraffic) / float64(user.DataLimit) * 100
+	}
+
+	if user.Expire == 0 {
+		summary.NeverExpires = true
+	} else {
+		summary.ExpiresAt = time.Unix(user.Expire, 0)
+		summary.RemainingDays = max(int(summary.ExpiresAt.Sub(now)/(24*time.Hour)), 0)
+	}
+
+	return summary
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SystemStats is the panel-wide summary served by GET /api/system.
+// Bandwidth figures are in bytes, speeds in bytes per second.
+type SystemStats struct {
+	Version                string  `json:"version"`
+	MemTotal               int64   `json:"mem_total"`
+	MemUsed                int64   `json:"mem_used"`
+	CPUCores               int     `json:"cpu_cores"`
+	CPUUsage               float64 `json:"cpu_usage"`
+	TotalUser              int     `json:"total_user"`
+	OnlineUsers            int     `json:"online_users"`
+	UsersActive            int     `json:"users_active"`
+	UsersOnHold            int     `json:"users_on_hold"`
+	UsersDisabled          int     `json:"users_disabled"`
+	UsersExpired           int     `json:"users_expired"`
+	UsersLimited           int     `json:"users_limited"`
+	IncomingBandwidth      int64   `json:"incoming_bandwidth"`
+	OutgoingBandwidth      int64   `json:"outgoing_bandwidth"`
+	IncomingBandwidthSpeed int64   `json:"incoming_bandwidth_speed"`
+	OutgoingBandwidthSpeed int64   `json:"outgoing_bandwidth_speed"`
+}
+
+func (m *marzban) GetSystemStats() (SystemStats, error) {
+	return m.GetSystemStatsCtx(context.Background())
+}
+
+func (m *marzban) GetSystemStatsCtx(ctx context.Context) (SystemStats, error) {
+	var stats SystemStats
+	err := m.do(ctx, "GET", API_SYSTEM_PATH, nil, &stats)
+	return stats, err
+}
+
+// Ping logs in afresh and fetches the system stats, returning nil only when
+// both succeed. Call it before provisioning to fail fast on a wrong
+// BaseURL or bad credentials.
+func (m *marzban) Ping() error {
+	return m.PingCtx(context.Background())
+}
+
+func (m *marzban) PingCtx(ctx context.Context) error {
+	// Drop any cached token so the credentials themselves are checked.
+	m.InvalidateToken()
+
+	return m.do(ctx, "GET", API_SYSTEM_PATH, nil, nil)
+}
+
+// WaitForPanel calls Ping every interval until it succeeds, for use right
+// after an install while the panel container is still starting. It gives up
+// when ctx is done, returning the last Ping error, or at once when the
+// panel rejects the credentials with a 401; a 502/503 from the starting
+// panel is waited out. A non-positive interval means DEFAULT_WAIT_INTERVAL.
+func (m *marzban) WaitForPanel(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DEFAULT_WAIT_INTERVAL
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		err := m.PingCtx(ctx)
+		if err == nil || errors.Is(err, ErrUnauthorized) {
+			return err
+		}
+		// A Ping cut short by ctx says less than the one before it, e.g.
+		// the panel's 503.
+		if ctx.Err() == nil || lastErr == nil {
+			lastErr = err
+		}
+		m.config.Logger.Debug("panel not ready", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("panel not ready: %w", lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Inbound is an xray inbound the panel can assign users to.
+type Inbound struct {
+	Tag      string `json:"tag"`
+	Protocol string `json:"protocol"`
+	Network  string `json:"network"`
+	TLS      string `json:"tls"`
+	// Port is a number, or a string for port ranges and lists.
+	Port any `json:"port"`
+}
+
+// GetInbounds returns the configured inbounds keyed by protocol, so a
+// CreateUserRequest's Proxies can be checked against them up front.
+func (m *marzban) GetInbounds() (map[string][]Inbound, error) {
+	return m.GetInboundsCtx(context.Background())
+}
+
+func (m *marzban) GetInboundsCtx(ctx context.Context) (map[string][]Inbound, error) {
+	var inbounds map[string][]Inbound
+	err := m.do(ctx, "GET", API_INBOUNDS_PATH, nil, &inbounds)
+	return inbounds, err
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startingPanel answers logins with 503 for the first unavailable attempts,
+// like a panel whose container is still starting, and with status after
+// that.
+func startingPanel(t *testing.T, unavailable int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var logins atomic.Int32
+	panel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != API_AUTH_PATH {
+			writeJSON(t, w, SystemStats{Version: "0.8.4"})
+			return
+		}
+		if logins.Add(1) <= unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		writeJSON(t, w, Token{AccessToken: testToken, TokenType: "bearer"})
+	}))
+	t.Cleanup(panel.Close)
+
+	return panel, &logins
+}
+
+func startingClient(panel *httptest.Server) Marzban {
+	return NewMarzbanClientWithConfig(Config{
+		BaseURL:    panel.URL,
+		Username:   testUsername,
+		Password:   testPassword,
+		MaxRetries: -1,
+	})
+}
+
+func TestWaitForPanelStarting(t *testing.T) {
+	panel, logins := startingPanel(t, 3, http.StatusOK)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := startingClient(panel).WaitForPanel(ctx, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := logins.Load(); got != 4 {
+		t.Errorf("logins = %d, want 4", got)
+	}
+}
+
+func TestWaitForPanelRejected(t *testing.T) {
+	panel, logins := startingPanel(t, 1, http.StatusUnauthorized)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := startingClient(panel).WaitForPanel(ctx, 10*time.Millisecond)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+	if got := logins.Load(); got != 2 {
+		t.Errorf("logins = %d, want 2", got)
+	}
+}
+
+func TestWaitForPanelGivesUp(t *testing.T) {
+	panel, _ := startingPanel(t, 1<<30, http.StatusOK)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := startingClient(panel).WaitForPanel(ctx, 10*time.Millisecond)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("err = %v, want the last 503", err)
+	}
+}
+
+func TestWaitForPanelZeroInterval(t *testing.T) {
+	panel, _ := startingPanel(t, 0, http.StatusOK)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		err := startingClient(panel).WaitForPanel(context.Background(), interval)
+		if err != nil {
+			t.Errorf("interval %s: %v", interval, err)
+		}
+	}
+}
@@ -1,26 +1,153 @@
 package client
 
+import "time"
+
 type Token struct {
-	AccessToen string `json:"access_token"`
-	TokenType  string `json:"token_type"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
 }
 
-var (
-	API_AUTH_URL    = "https://127.0.0.1:8000/api/admin/token"
-	API_CREATE_USER = "https://127.0.0.1:8000:8000/api/user"
-	API_GET_USER    = "https://127.0.0.1:8000:8000/api/user/"
-)
-
-const (
-	DATA_LIMIT_10GB  = 10737418240
-	DATA_LIMIT_15GB  = 16106127360
-	DATA_LIMIT_20GB  = 21474836480
-	DATA_LIMIT_50GB  = 53687091200
-	DATA_LIMIT_100GB = 107374182400
-	DATA_LIMIT_30GB  = 32212254720
-	DATA_LIMIT_40GB  = 42949672960
-	DATA_LIMIT_60GB  = 64424509440
-	DATA_LIMIT_70GB  = 75161927680
-	DATA_LIMIT_80GB  = 85899345920
-	DATA_LIMIT_90GB  = 96636764160
-)
\ No newline at end of file
+const (
+	DEFAULT_BASE_URL = "https://127.0.0.1:8000"
+	DEFAULT_USERNAME = "admin"
+	DEFAULT_PASSWORD = "admin"
+	DEFAULT_TIMEOUT  = 30 * time.Second
+
+	DEFAULT_MAX_RETRIES = 2
+	RETRY_BASE_DELAY    = 500 * time.Millisecond
+
+	// DEFAULT_WAIT_INTERVAL is used by WaitForPanel for a non-positive
+	// interval.
+	DEFAULT_WAIT_INTERVAL = 2 * time.Second
+)
+
+// Marzban issues tokens valid for ACCESS_TOKEN_EXPIRE_MINUTES (1440 by
+// default); cached tokens are renewed a little before that.
+const (
+	TOKEN_LIFETIME       = 1440 * time.Minute
+	TOKEN_REFRESH_MARGIN = time.Minute
+)
+
+const (
+	API_AUTH_PATH  = "/api/admin/token"
+	API_USER_PATH  = "/api/user"
+	API_USERS_PATH = "/api/users"
+
+	API_SYSTEM_PATH   = "/api/system"
+	API_INBOUNDS_PATH = "/api/inbounds"
+	API_NODE_PATH     = "/api/node"
+	API_NODES_PATH    = "/api/nodes"
+	API_ADMIN_PATH    = "/api/admin"
+	API_ADMINS_PATH   = "/api/admins"
+
+	API_CORE_PATH        = "/api/core"
+	API_CORE_CONFIG_PATH = "/api/core/config"
+)
+
+// USAGE_TIME_FORMAT is the naive UTC ISO 8601 form the usage endpoints parse.
+const USAGE_TIME_FORMAT = "2006-01-02T15:04:05"
+
+// EXPIRY_DATE_LAYOUTS are the absolute dates ParseExpiryDate accepts.
+var EXPIRY_DATE_LAYOUTS = []string{time.RFC3339, time.DateOnly}
+
+// LIST_PAGE_SIZE is the page size used by ListAllMarzbanUsers.
+const LIST_PAGE_SIZE = 100
+
+const (
+	USERNAME_MIN_LENGTH = 3
+	USERNAME_MAX_LENGTH = 32
+)
+
+// BULK_CONCURRENCY bounds the requests in flight in CreateMarzbanUsers.
+const BULK_CONCURRENCY = 4
+
+// MAX_ERROR_BODY caps how much of an error response is kept in APIError.
+const MAX_ERROR_BODY = 4096
+
+// MAX_DECODE_ERROR_BODY caps how much of an unparsable body is kept in
+// DecodeError.
+const MAX_DECODE_ERROR_BODY = 512
+
+const (
+	WEBHOOK_EVENT_USER_CREATED = "user_created"
+	WEBHOOK_SIGNATURE_HEADER   = "X-Marzban-Signature"
+)
+
+const (
+	PROTOCOL_VLESS       = "vless"
+	PROTOCOL_VMESS       = "vmess"
+	PROTOCOL_TROJAN      = "trojan"
+	PROTOCOL_SHADOWSOCKS = "shadowsocks"
+)
+
+// PROTOCOLS lists the proxy protocols a user can be provisioned with.
+var PROTOCOLS = []string{PROTOCOL_VLESS, PROTOCOL_VMESS, PROTOCOL_TROJAN, PROTOCOL_SHADOWSOCKS}
+
+// FLOW_XTLS_RPRX_VISION is the vless flow required by XTLS Vision inbounds,
+// e.g. VLESS over TCP with REALITY.
+const FLOW_XTLS_RPRX_VISION = "xtls-rprx-vision"
+
+const (
+	USER_STATUS_ACTIVE   = "active"
+	USER_STATUS_ON_HOLD  = "on_hold"
+	USER_STATUS_DISABLED = "disabled"
+	USER_STATUS_LIMITED  = "limited"
+	USER_STATUS_EXPIRED  = "expired"
+)
+
+// USER_STATUSES lists the statuses a user can be in, e.g. for filtering with
+// ListUsersOptions.
+var USER_STATUSES = []string{
+	USER_STATUS_ACTIVE,
+	USER_STATUS_ON_HOLD,
+	USER_STATUS_DISABLED,
+	USER_STATUS_LIMITED,
+	USER_STATUS_EXPIRED,
+}
+
+const (
+	RESET_STRATEGY_NO_RESET = "no_reset"
+	RESET_STRATEGY_DAY      = "day"
+	RESET_STRATEGY_WEEK     = "week"
+	RESET_STRATEGY_MONTH    = "month"
+	RESET_STRATEGY_YEAR     = "year"
+)
+
+// RESET_STRATEGIES lists the accepted data_limit_reset_strategy values.
+var RESET_STRATEGIES = []string{
+	RESET_STRATEGY_NO_RESET,
+	RESET_STRATEGY_DAY,
+	RESET_STRATEGY_WEEK,
+	RESET_STRATEGY_MONTH,
+	RESET_STRATEGY_YEAR,
+}
+
+const BYTES_PER_GB = 1 << 30
+
+// DataLimit is a traffic quota in bytes. The panel reads a data_limit of 0
+// as "no quota", so Unlimited is 0; set it to ask for an unlimited user on
+// purpose, and use GenerateData or BytesFromGB for real quotas.
+type DataLimit int64
+
+const Unlimited DataLimit = 0
+
+func (d DataLimit) IsUnlimited() bool {
+	return d == Unlimited
+}
+
+// DATA_LIMIT_PRESETS lists the quotas, in GB, accepted by GenerateData.
+var DATA_LIMIT_PRESETS = []int{10, 15, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+
+const (
+	DATA_LIMIT_10GB  = 10 * BYTES_PER_GB
+	DATA_LIMIT_15GB  = 15 * BYTES_PER_GB
+	DATA_LIMIT_20GB  = 20 * BYTES_PER_GB
+	DATA_LIMIT_50GB  = 50 * BYTES_PER_GB
+	DATA_LIMIT_100GB = 100 * BYTES_PER_GB
+	DATA_LIMIT_30GB  = 30 * BYTES_PER_GB
+	DATA_LIMIT_40GB  = 40 * BYTES_PER_GB
+	DATA_LIMIT_60GB  = 60 * BYTES_PER_GB
+	DATA_LIMIT_70GB  = 70 * BYTES_PER_GB
+	DATA_LIMIT_80GB  = 80 * BYTES_PER_GB
+	DATA_LIMIT_90GB  = 90 * BYTES_PER_GB
+)
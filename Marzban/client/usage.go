@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// UsageSeries is a user's traffic over a period, split per node.
+type UsageSeries struct {
+	Username string      `json:"username"`
+	Usages   []NodeUsage `json:"usages"`
+}
+
+// NodeUsage is the traffic, in bytes, a user sent through one node. A nil
+// NodeID is the panel's own core.
+type NodeUsage struct {
+	NodeID      *int   `json:"node_id"` // Pointer to handle null
+	NodeName    string `json:"node_name"`
+	UsedTraffic int64  `json:"used_traffic"`
+}
+
+func (m *marzban) GetUserUsage(username string, start, end time.Time) (UsageSeries, error) {
+	return m.GetUserUsageCtx(context.Background(), username, start, end)
+}
+
+// GetUserUsageCtx returns the user's traffic between start and end. A zero
+// start or end leaves that bound to the panel (by default the last 30 days).
+func (m *marzban) GetUserUsageCtx(ctx context.Context, username string, start, end time.Time) (UsageSeries, error) {
+	var series UsageSeries
+
+	query := url.Values{}
+	if !start.IsZero() {
+		query.Set("start", start.UTC().Format(USAGE_TIME_FORMAT))
+	}
+	if !end.IsZero() {
+		query.Set("end", end.UTC().Format(USAGE_TIME_FORMAT))
+	}
+
+	path := userPath(username) + "/usage"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	err := m.do(ctx, "GET", path, nil, &series)
+	return series, userNotFound(err, username)
+}
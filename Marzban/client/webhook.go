@@ -0,0 +1,81 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WebhookConfig points the client at an endpoint that is told about user
+// lifecycle events. With a Secret, every body is signed with HMAC-SHA256 and
+// the hex digest is sent as "sha256=<digest>" in WEBHOOK_SIGNATURE_HEADER.
+// The endpoint's TLS certificate is always verified, whatever
+// Config.InsecureSkipVerify says.
+type WebhookConfig struct {
+	URL    string
+	Secret string
+}
+
+// WebhookEvent is the JSON body POSTed to WebhookConfig.URL.
+type WebhookEvent struct {
+	Event    string   `json:"event"`
+	Username string   `json:"username"`
+	Links    []string `json:"links"`
+	Expire   int64    `json:"expire"`
+}
+
+// SignWebhook returns the value of WEBHOOK_SIGNATURE_HEADER for body, so
+// receivers can verify deliveries with the same secret.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhook delivers event when a webhook is configured. Delivery is
+// best effort: failures are logged and never reach the caller.
+func (m *marzban) notifyWebhook(ctx context.Context, event WebhookEvent) {
+	if m.config.Webhook.URL == "" {
+		return
+	}
+
+	err := m.deliverWebhook(ctx, event)
+	if err != nil {
+		m.config.Logger.Warn("webhook delivery failed", "event", event.Event, "username", event.Username, "error", err)
+	}
+}
+
+func (m *marzban) deliverWebhook(ctx context.Context, event WebhookEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.config.Webhook.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.config.Webhook.Secret != "" {
+		req.Header.Set(WEBHOOK_SIGNATURE_HEADER, SignWebhook(m.config.Webhook.Secret, data))
+	}
+
+	resp, err := m.webhookHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	const secret = "webhook-secret"
+
+	var body []byte
+	var signature string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(WEBHOOK_SIGNATURE_HEADER)
+	}))
+	defer hook.Close()
+
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, userResponse("alice"))
+	})
+	m := panel.client(func(cfg *Config) {
+		cfg.Webhook = WebhookConfig{URL: hook.URL, Secret: secret}
+	})
+
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+
+	var event WebhookEvent
+	err = json.Unmarshal(body, &event)
+	if err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if event.Event != WEBHOOK_EVENT_USER_CREATED || event.Username != "alice" || len(event.Links) != 1 {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestWebhookWithoutSecretIsUnsigned(t *testing.T) {
+	var signed atomic.Bool
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signed.Store(r.Header.Get(WEBHOOK_SIGNATURE_HEADER) != "")
+	}))
+	defer hook.Close()
+
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, userResponse("alice"))
+	})
+	m := panel.client(func(cfg *Config) {
+		cfg.Webhook = WebhookConfig{URL: hook.URL}
+	})
+
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Load() {
+		t.Errorf("%s sent without a secret", WEBHOOK_SIGNATURE_HEADER)
+	}
+}
+
+// InsecureSkipVerify is for the panel only; a webhook endpoint with an
+// untrusted certificate must not receive the user's links.
+func TestWebhookVerifiesCertificate(t *testing.T) {
+	var delivered atomic.Bool
+	hook := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered.Store(true)
+	}))
+	// The rejected handshake is expected; keep it out of the test output.
+	hook.Config.ErrorLog = log.New(io.Discard, "", 0)
+	hook.StartTLS()
+	defer hook.Close()
+
+	panel := newTestPanel(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, userResponse("alice"))
+	})
+	m := panel.client(func(cfg *Config) {
+		cfg.InsecureSkipVerify = true
+		cfg.Webhook = WebhookConfig{URL: hook.URL, Secret: "webhook-secret"}
+	})
+
+	_, err := m.CreateMarzbanUser(CreateUserRequest{Username: "alice"})
+	if err != nil {
+		t.Fatalf("a failed delivery must not fail the create: %v", err)
+	}
+	if delivered.Load() {
+		t.Error("webhook delivered to a server with an untrusted certificate")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"Marzban/installer"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newInstallCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install Marzban with the official script",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			boolFlag(cmd, "force", &c.cfg.Installer.Force)
+			durationFlag(cmd, "timeout", &c.cfg.Installer.Timeout)
+			stringFlag(cmd, "sha256", &c.cfg.Installer.ExpectedSHA256)
+			stringFlag(cmd, "privilege-command", &c.cfg.Installer.PrivilegeCommand)
+			stringFlag(cmd, "script-ref", &c.cfg.Installer.ScriptRef)
+			stringFlag(cmd, "script-url", &c.cfg.Installer.ScriptURL)
+
+			setupConfig, err := c.setupConfig()
+			if err != nil {
+				return err
+			}
+			opts := setupConfig.Install
+			opts.DryRun = setupConfig.DryRun
+
+			err = installer.Install_MarzbanWithOptionsCtx(cmd.Context(), opts)
+			if errors.Is(err, installer.ErrAlreadyInstalled) {
+				return fmt.Errorf("%w (use --force to reinstall)", err)
+			}
+			return err
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.Bool("force", false, "install even if marzban is already present")
+	flags.Duration("timeout", 0, "time allowed for the install script (default 2m)")
+	flags.String("sha256", "", "expected SHA-256 of the install script")
+	flags.String("privilege-command", "", `command used to run the script as root, or "none"`)
+	flags.String("script-ref", "", "branch or tag of the install script (default "+installer.DEFAULT_SCRIPT_REF+")")
+	flags.String("script-url", "", "download the install script from this URL instead of GitHub")
+
+	return cmd
+}
+
+func newUninstallCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove Marzban with the official script",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stringFlag(cmd, "privilege-command", &c.cfg.Installer.PrivilegeCommand)
+
+			setupConfig, err := c.setupConfig()
+			if err != nil {
+				return err
+			}
+			opts := setupConfig.Install
+			opts.DryRun = setupConfig.DryRun
+			opts.RemoveData, _ = cmd.Flags().GetBool("remove-data")
+
+			return installer.Uninstall_MarzbanWithOptionsCtx(cmd.Context(), opts)
+		},
+	}
+
+	cmd.Flags().String("privilege-command", "", `command used to run the script as root, or "none"`)
+	cmd.Flags().Bool("remove-data", false, "also delete the panel's data directory, including the database")
+
+	return cmd
+}
+
+func newInstallNodeCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install-node",
+		Short: "Install marzban-node with the official script",
+		Long: "Install marzban-node so a panel can use this server as a node.\n" +
+			"--cert is the client certificate shown on the panel's node settings page.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			durationFlag(cmd, "timeout", &c.cfg.Installer.Timeout)
+			stringFlag(cmd, "privilege-command", &c.cfg.Installer.PrivilegeCommand)
+			stringFlag(cmd, "script-ref", &c.cfg.Installer.ScriptRef)
+			stringFlag(cmd, "script-url", &c.cfg.Installer.ScriptURL)
+
+			certPath, _ := cmd.Flags().GetString("cert")
+			cert, err := os.ReadFile(certPath)
+			if err != nil {
+				return err
+			}
+
+			setupConfig, err := c.setupConfig()
+			if err != nil {
+				return err
+			}
+			opts := setupConfig.Install
+			opts.DryRun = setupConfig.DryRun
+
+			return installer.Install_MarzbanNodeWithOptionsCtx(cmd.Context(), string(cert), opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.String("cert", "", "file holding the panel's client certificate (PEM)")
+	flags.Duration("timeout", 0, "time allowed for the install script (default 2m)")
+	flags.String("privilege-command", "", `command used to run the script as root, or "none"`)
+	flags.String("script-ref", "", "branch or tag of the install script (default "+installer.DEFAULT_SCRIPT_REF+")")
+	flags.String("script-url", "", "download the install script from this URL instead of GitHub")
+	cmd.MarkFlagRequired("cert")
+
+	return cmd
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"Marzban/installer"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newLogsCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Show the Marzban panel logs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lines, _ := cmd.Flags().GetInt("tail")
+			follow, _ := cmd.Flags().GetBool("follow")
+			stringFlag(cmd, "privilege-command", &c.cfg.Installer.PrivilegeCommand)
+			opts := installer.Options{PrivilegeCommand: c.cfg.Installer.PrivilegeCommand}
+
+			if follow {
+				return installer.StreamMarzbanLogsWithOptions(cmd.Context(), cmd.OutOrStdout(), lines, opts)
+			}
+
+			logs, err := installer.MarzbanLogsWithOptions(lines, opts)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprint(cmd.OutOrStdout(), logs)
+			return err
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.Int("tail", installer.DEFAULT_LOG_LINES, "number of lines to show, 0 for all")
+	flags.BoolP("follow", "f", false, "keep printing new log lines")
+	flags.String("privilege-command", "", `command used to run docker as root, or "none"`)
+
+	return cmd
+}
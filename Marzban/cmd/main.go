@@ -1,34 +1,21 @@
 package main
 
 import (
-	"Marzban/client"
-	"Marzban/installer"
-	"Marzban/replacer"
-	"fmt"
-	"log"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	err := installer.Install_Marzban()
-	if err != nil {
-		log.Println("Instalation Error", err)
-	}
-
-	err = replacer.Replace_xray()
-	if err != nil {
-		log.Println("Configuration Error", err)
-	}
+	// Ctrl-C cancels the running command, e.g. killing a stuck install
+	// script, instead of leaving it behind.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	err = replacer.Replace_env()
+	err := newRootCmd().ExecuteContext(ctx)
 	if err != nil {
-		log.Println("Configuration Error", err)
+		stop()
+		os.Exit(1)
 	}
-
-	panel := client.NewMarzbanClient()
-	resp, err := panel.CreateMarzbanUser("admin")
-	if err != nil {
-		log.Println("User Inbound Error", err)
-	}
-
-	fmt.Println(resp.Links)
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"Marzban/client"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestUserCreate drives "user create" end to end against a fake panel, so
+// the command and the client it calls are checked together.
+func TestUserCreate(t *testing.T) {
+	var body map[string]any
+	panel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case client.API_AUTH_PATH:
+			json.NewEncoder(w).Encode(client.Token{AccessToken: "token", TokenType: "bearer"})
+		case client.API_USER_PATH:
+			err := json.NewDecoder(r.Body).Decode(&body)
+			if err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			json.NewEncoder(w).Encode(client.Response{
+				Username: "alice",
+				Links:    []string{"vless://uuid@example.com:443#alice"},
+			})
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer panel.Close()
+
+	t.Setenv("MARZBAN_BASE_URL", panel.URL)
+	t.Setenv("MARZBAN_USERNAME", "operator")
+	t.Setenv("MARZBAN_PASSWORD", "s3cret")
+
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"user", "create", "--username", "alice", "--data-limit", "25", "--expire", "30d"})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body["username"] != "alice" || body["data_limit"] != float64(client.BytesFromGB(25)) {
+		t.Errorf("body = %v", body)
+	}
+	if expire, _ := body["expire"].(float64); expire == 0 {
+		t.Errorf("expire = %v, want a timestamp", body["expire"])
+	}
+	if !strings.Contains(out.String(), "vless://uuid@example.com:443#alice") {
+		t.Errorf("output does not list the link:\n%s", out.String())
+	}
+}
+
+func TestUserCreateDryRun(t *testing.T) {
+	t.Setenv("MARZBAN_USERNAME", "operator")
+	t.Setenv("MARZBAN_PASSWORD", "s3cret")
+
+	var out bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--dry-run", "user", "create", "--username", "alice"})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "would create user alice") {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestUserCreateRequiresUsername(t *testing.T) {
+	panel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+	}))
+	defer panel.Close()
+	t.Setenv("MARZBAN_BASE_URL", panel.URL)
+
+	for _, args := range [][]string{
+		{"user", "create"},
+		{"user", "create", "--username", ""},
+	} {
+		cmd := newRootCmd()
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(args)
+
+		err := cmd.Execute()
+		if err == nil {
+			t.Errorf("%q created a user", args)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"Marzban/replacer"
+
+	"github.com/spf13/cobra"
+)
+
+func newReplaceCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replace",
+		Short: "Replace xray_config.json and .env, keeping backups",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stringFlag(cmd, "xray-src", &c.cfg.Replacer.XraySrc)
+			stringFlag(cmd, "xray-dst", &c.cfg.Replacer.XrayDst)
+			stringFlag(cmd, "env-src", &c.cfg.Replacer.EnvSrc)
+			stringFlag(cmd, "env-dst", &c.cfg.Replacer.EnvDst)
+			boolFlag(cmd, "show-diff", &c.cfg.Replacer.ShowDiff)
+			boolFlag(cmd, "create-dirs", &c.cfg.Replacer.CreateDirs)
+			boolFlag(cmd, "restart", &c.cfg.Replacer.RestartAfterReplace)
+			stringFlag(cmd, "privilege-command", &c.cfg.Installer.PrivilegeCommand)
+			if cmd.Flags().Changed("require-env") {
+				c.cfg.Replacer.RequiredEnvKeys, _ = cmd.Flags().GetStringSlice("require-env")
+			}
+
+			setupConfig, err := c.setupConfig()
+			if err != nil {
+				return err
+			}
+			cfg := setupConfig.Replacer
+			cfg.DryRun = setupConfig.DryRun
+			cfg.Output = cmd.OutOrStdout()
+
+			return replacer.Replace_allWithConfig(cfg)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.String("xray-src", "", "xray config to install (default "+replacer.DEFAULT_XRAY_SRC+")")
+	flags.String("xray-dst", "", "xray config to overwrite (default "+replacer.DEFAULT_XRAY_DST+")")
+	flags.String("env-src", "", ".env to install (default "+replacer.DEFAULT_ENV_SRC+")")
+	flags.String("env-dst", "", ".env to overwrite (default "+replacer.DEFAULT_ENV_DST+")")
+	flags.Bool("show-diff", false, "print a diff of each file before replacing it")
+	flags.Bool("create-dirs", false, "create missing destination directories")
+	flags.Bool("restart", false, "restart marzban after replacing the files")
+	flags.String("privilege-command", "", `command used to run the restart as root, or "none"`)
+	flags.StringSlice("require-env", nil, "keys that must be set in the new .env, e.g. SQLALCHEMY_DATABASE_URL")
+
+	return cmd
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"Marzban/config"
+	"Marzban/installer"
+	"Marzban/replacer"
+	"Marzban/setup"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// cli holds what every command shares: the global flags and the config
+// they resolve to.
+type cli struct {
+	configPath string
+	verbose    bool
+	dryRun     bool
+
+	cfg    *config.Config
+	logger *slog.Logger
+}
+
+func newRootCmd() *cobra.Command {
+	c := &cli{}
+
+	root := &cobra.Command{
+		Use:               "marzban-setup",
+		Short:             "Install, configure and manage a Marzban panel",
+		SilenceUsage:      true,
+		PersistentPreRunE: c.load,
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&c.configPath, "config", "", "path to a JSON config file")
+	flags.BoolVar(&c.verbose, "verbose", false, "log debug output")
+	flags.BoolVar(&c.dryRun, "dry-run", false, "show what would change without changing anything")
+
+	root.AddCommand(
+		newSetupCmd(c),
+		newInstallCmd(c),
+		newUninstallCmd(c),
+		newInstallNodeCmd(c),
+		newLogsCmd(c),
+		newStatusCmd(c),
+		newReplaceCmd(c),
+		newUserCmd(c),
+	)
+
+	return root
+}
+
+// load sets up logging and reads the config file and MARZBAN_* variables.
+// Each command then applies its own flags on top.
+func (c *cli) load(cmd *cobra.Command, args []string) error {
+	level := slog.LevelInfo
+	if c.verbose {
+		level = slog.LevelDebug
+	}
+	c.logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	installer.SetLogger(c.logger)
+	replacer.SetLogger(c.logger)
+	setup.SetLogger(c.logger)
+
+	cfg := config.Default()
+	if c.configPath != "" {
+		var err error
+		cfg, err = config.LoadConfig(c.configPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Environment variables take precedence over the file.
+	err := cfg.ApplyEnv()
+	if err != nil {
+		return err
+	}
+	if c.dryRun {
+		cfg.DryRun = true
+	}
+
+	c.cfg = cfg
+	return nil
+}
+
+// setupConfig validates the config with all flags applied and converts it.
+func (c *cli) setupConfig() (setup.SetupConfig, error) {
+	err := c.cfg.Validate()
+	if err != nil {
+		return setup.SetupConfig{}, err
+	}
+
+	setupConfig, err := c.cfg.SetupConfig()
+	if err != nil {
+		return setup.SetupConfig{}, err
+	}
+	setupConfig.Client.Logger = c.logger
+
+	return setupConfig, nil
+}
+
+// The flag helpers copy a flag into the config only when it was given, so
+// unset flags do not clobber values from the file or environment.
+
+func stringFlag(cmd *cobra.Command, name string, dst *string) {
+	if cmd.Flags().Changed(name) {
+		*dst, _ = cmd.Flags().GetString(name)
+	}
+}
+
+func boolFlag(cmd *cobra.Command, name string, dst *bool) {
+	if cmd.Flags().Changed(name) {
+		*dst, _ = cmd.Flags().GetBool(name)
+	}
+}
+
+func durationFlag(cmd *cobra.Command, name string, dst *config.Duration) {
+	if cmd.Flags().Changed(name) {
+		d, _ := cmd.Flags().GetDuration(name)
+		*dst = config.Duration(d)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"Marzban/client"
+	"Marzban/setup"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newSetupCmd(c *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "setup",
+		Short: "Install the panel, replace its config files and create the first user",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			setupConfig, err := c.setupConfig()
+			if err != nil {
+				return err
+			}
+			setupConfig.OnUserCreated = func(resp client.Response) {
+				for _, link := range resp.Links {
+					fmt.Fprintln(cmd.OutOrStdout(), link)
+				}
+			}
+
+			return setup.RunSetupCtx(cmd.Context(), setupConfig)
+		},
+	}
+}
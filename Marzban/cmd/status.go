@@ -0,0 +1,51 @@
+package main
+
+import (
+	"Marzban/installer"
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newStatusCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the Marzban containers are running",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			wait, _ := cmd.Flags().GetDuration("wait")
+			stringFlag(cmd, "privilege-command", &c.cfg.Installer.PrivilegeCommand)
+			opts := installer.Options{PrivilegeCommand: c.cfg.Installer.PrivilegeCommand}
+
+			var status installer.Status
+			var err error
+			if wait > 0 {
+				ctx, cancel := context.WithTimeout(cmd.Context(), wait)
+				defer cancel()
+				status, err = installer.WaitForMarzbanRunningWithOptions(ctx, opts)
+			} else {
+				status, err = installer.MarzbanStatusWithOptions(opts)
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "running: %t\nversion: %s\n", status.Running, status.Version)
+			if len(status.Containers) > 0 {
+				w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "SERVICE\tSTATE\tIMAGE")
+				for _, container := range status.Containers {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", container.Service, container.State, container.Image)
+				}
+				w.Flush()
+			}
+
+			return err
+		},
+	}
+
+	cmd.Flags().Duration("wait", 0, "poll until the panel is running or this much time has passed")
+	cmd.Flags().String("privilege-command", "", `command used to run docker as root, or "none"`)
+
+	return cmd
+}
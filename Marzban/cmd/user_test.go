@@ -0,0 +1,40 @@
+package main
+
+import (
+	"Marzban/client"
+	"testing"
+)
+
+func TestParseDataLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"25", 25},
+		{"0", 0},
+		{"0.5", 0.5},
+		{"50GiB", 50},
+		{"50gib", 50},
+		{"512MiB", 0.5},
+		{"50GB", 50e9 / client.BYTES_PER_GB},
+	}
+	for _, tt := range tests {
+		got, err := parseDataLimit(tt.in)
+		if err != nil {
+			t.Errorf("parseDataLimit(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDataLimit(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	// strconv.ParseFloat accepts all of these, which used to let them
+	// through as a number of GB.
+	for _, in := range []string{"NaN", "Inf", "-Inf", "1e3", "-5", "0x1p3", ""} {
+		got, err := parseDataLimit(in)
+		if err == nil {
+			t.Errorf("parseDataLimit(%q) = %v, want an error", in, got)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"Marzban/client"
+	"Marzban/installer"
+	"Marzban/notify"
+	"Marzban/replacer"
+	"Marzban/setup"
+)
+
+// Config is the on-disk configuration of a setup run. It is read from JSON;
+// durations are written as strings such as "90s" or "10m".
+type Config struct {
+	Client    ClientConfig    `json:"client"`
+	Installer InstallerConfig `json:"installer"`
+	Replacer  ReplacerConfig  `json:"replacer"`
+	User      UserConfig      `json:"user"`
+	Notify    NotifyConfig    `json:"notify"`
+	// DryRun previews the whole run without changing anything.
+	DryRun bool `json:"dry_run"`
+}
+
+type ClientConfig struct {
+	BaseURL            string   `json:"base_url"`
+	Username           string   `json:"username"`
+	Password           string   `json:"password"`
+	Timeout            Duration `json:"timeout"`
+	InsecureSkipVerify bool     `json:"insecure_skip_verify"`
+	RateLimit          float64  `json:"rate_limit"`
+	MaxRetries         int      `json:"max_retries"`
+	WebhookURL         string   `json:"webhook_url"`
+	WebhookSecret      string   `json:"webhook_secret"`
+	// Headers are sent with every panel request, e.g. Cloudflare Access
+	// service token headers.
+	Headers map[string]string `json:"headers"`
+}
+
+type InstallerConfig struct {
+	Skip           bool     `json:"skip"`
+	Force          bool     `json:"force"`
+	Timeout        Duration `json:"timeout"`
+	ExpectedSHA256 string   `json:"expected_sha256"`
+	// PrivilegeCommand replaces sudo, e.g. "doas"; "none" runs unprivileged.
+	PrivilegeCommand string `json:"privilege_command"`
+	// ScriptRef pins the install script to a branch or tag; ScriptURL
+	// replaces its URL, e.g. with a mirror.
+	ScriptRef string `json:"script_ref"`
+	ScriptURL string `json:"script_url"`
+}
+
+type ReplacerConfig struct {
+	XraySrc    string `json:"xray_src"`
+	XrayDst    string `json:"xray_dst"`
+	EnvSrc     string `json:"env_src"`
+	EnvDst     string `json:"env_dst"`
+	ShowDiff   bool   `json:"show_diff"`
+	CreateDirs bool   `json:"create_dirs"`
+	// RequiredEnvKeys are checked to be non-empty in env_src before the
+	// .env is replaced.
+	RequiredEnvKeys []string `json:"required_env_keys"`
+	// RestartAfterReplace restarts marzban once the files are replaced.
+	RestartAfterReplace bool `json:"restart_after_replace"`
+}
+
+// UserConfig describes the initial user. Expire accepts anything
+// client.ParseExpiry does, e.g. "30d", "3mo" or a date such as
+// "2025-07-01". A data_limit_gb of 0, the default, creates an unlimited
+// user.
+type UserConfig struct {
+	Username               string                          `json:"username"`
+	DataLimitGB            float64                         `json:"data_limit_gb"`
+	Expire                 string                          `json:"expire"`
+	DataLimitResetStrategy string                          `json:"data_limit_reset_strategy"`
+	Status                 string                          `json:"status"`
+	OnHoldExpireDuration   int64                           `json:"on_hold_expire_duration"`
+	Note                   string                          `json:"note"`
+	Proxies                map[string]client.ProxySettings `json:"proxies"`
+}
+
+// NotifyConfig enables notifications about the created user; leaving
+// telegram.bot_token empty disables Telegram.
+type NotifyConfig struct {
+	Telegram TelegramConfig `json:"telegram"`
+}
+
+type TelegramConfig struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+}
+
+// Duration is a time.Duration read from a string like "10m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return fmt.Errorf("duration must be a string such as \"10m\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Default returns the configuration used when no file is given: install
+// with default paths and create an "admin" user.
+func Default() *Config {
+	return &Config{
+		User: UserConfig{Username: "admin"},
+	}
+}
+
+// LoadConfig reads a JSON config file over Default. Unknown keys are
+// rejected so typos do not go unnoticed. The result is not validated, as
+// ApplyEnv may still fill in settings such as the password; call Validate
+// once everything is merged.
+func LoadConfig(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cfg := Default()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// SetupConfig converts the file layout into the options RunSetup takes.
+func (c *Config) SetupConfig() (setup.SetupConfig, error) {
+	var expire int64
+	if c.User.Expire != "" {
+		var err error
+		expire, err = client.ParseExpiry(c.User.Expire)
+		if err != nil {
+			return setup.SetupConfig{}, fmt.Errorf("user expire: %w", err)
+		}
+	}
+
+	var notifier notify.Notifier
+	if c.Notify.Telegram.BotToken != "" {
+		notifier = notify.NewTelegramNotifier(c.Notify.Telegram.BotToken, c.Notify.Telegram.ChatID)
+	}
+
+	return setup.SetupConfig{
+		Install: installer.Options{
+			Timeout:          time.Duration(c.Installer.Timeout),
+			Force:            c.Installer.Force,
+			ExpectedSHA256:   c.Installer.ExpectedSHA256,
+			PrivilegeCommand: c.Installer.PrivilegeCommand,
+			ScriptRef:        c.Installer.ScriptRef,
+			ScriptURL:        c.Installer.ScriptURL,
+		},
+		SkipInstall: c.Installer.Skip,
+		Replacer: replacer.ReplacerConfig{
+			XraySrc:             c.Replacer.XraySrc,
+			XrayDst:             c.Replacer.XrayDst,
+			EnvSrc:              c.Replacer.EnvSrc,
+			EnvDst:              c.Replacer.EnvDst,
+			ShowDiff:            c.Replacer.ShowDiff,
+			CreateDirs:          c.Replacer.CreateDirs,
+			RequiredEnvKeys:     c.Replacer.RequiredEnvKeys,
+			RestartAfterReplace: c.Replacer.RestartAfterReplace,
+			PrivilegeCommand:    c.Installer.PrivilegeCommand,
+		},
+		Client: client.Config{
+			BaseURL:            c.Client.BaseURL,
+			Username:           c.Client.Username,
+			Password:           c.Client.Password,
+			Timeout:            time.Duration(c.Client.Timeout),
+			InsecureSkipVerify: c.Client.InsecureSkipVerify,
+			RateLimit:          c.Client.RateLimit,
+			MaxRetries:         c.Client.MaxRetries,
+			Webhook: client.WebhookConfig{
+				URL:    c.Client.WebhookURL,
+				Secret: c.Client.WebhookSecret,
+			},
+			Headers: c.Client.Headers,
+		},
+		User: client.CreateUserRequest{
+			Username:               c.User.Username,
+			DataLimit:              client.DataLimit(client.BytesFromGB(c.User.DataLimitGB)),
+			Expire:                 expire,
+			Proxies:                c.User.Proxies,
+			DataLimitResetStrategy: c.User.DataLimitResetStrategy,
+			Status:                 c.User.Status,
+			OnHoldExpireDuration:   c.User.OnHoldExpireDuration,
+			Note:                   c.User.Note,
+		},
+		Notifier: notifier,
+		DryRun:   c.DryRun,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A password kept out of the file and supplied through the environment
+// must not make LoadConfig fail before ApplyEnv runs.
+func TestLoadConfigPasswordFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"client": {"username": "operator"}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MARZBAN_PASSWORD", "secret")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	err = cfg.ApplyEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.Validate()
+	if err != nil {
+		t.Fatalf("Validate after ApplyEnv: %v", err)
+	}
+	if cfg.Client.Password != "secret" {
+		t.Errorf("password = %q, want it from MARZBAN_PASSWORD", cfg.Client.Password)
+	}
+}
+
+func TestValidateDataLimit(t *testing.T) {
+	for _, value := range []string{"NaN", "Inf", "-1"} {
+		t.Setenv("MARZBAN_USER_DATA_LIMIT_GB", value)
+
+		_, err := LoadConfigFromEnv()
+		if err == nil {
+			t.Errorf("MARZBAN_USER_DATA_LIMIT_GB=%s was accepted", value)
+		}
+	}
+
+	t.Setenv("MARZBAN_USER_DATA_LIMIT_GB", "25.5")
+	cfg, err := LoadConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.User.DataLimitGB != 25.5 {
+		t.Errorf("DataLimitGB = %v, want 25.5", cfg.User.DataLimitGB)
+	}
+}
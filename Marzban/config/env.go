@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// LoadConfigFromEnv builds a Config from Default and the MARZBAN_*
+// environment variables read by ApplyEnv.
+func LoadConfigFromEnv() (*Config, error) {
+	cfg := Default()
+
+	err := cfg.ApplyEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// ApplyEnv overrides c with any MARZBAN_* variables that are set, so secrets
+// can come from the environment while the rest stays in a file.
+func (c *Config) ApplyEnv() error {
+	setString(&c.Client.BaseURL, "MARZBAN_BASE_URL")
+	setString(&c.Client.Username, "MARZBAN_USERNAME")
+	setString(&c.Client.Password, "MARZBAN_PASSWORD")
+	setString(&c.Client.WebhookURL, "MARZBAN_WEBHOOK_URL")
+	setString(&c.Client.WebhookSecret, "MARZBAN_WEBHOOK_SECRET")
+	setString(&c.Installer.ExpectedSHA256, "MARZBAN_SCRIPT_SHA256")
+	setString(&c.Installer.PrivilegeCommand, "MARZBAN_PRIVILEGE_COMMAND")
+	setString(&c.Installer.ScriptRef, "MARZBAN_SCRIPT_REF")
+	setString(&c.Installer.ScriptURL, "MARZBAN_SCRIPT_URL")
+	setString(&c.Replacer.XraySrc, "MARZBAN_XRAY_CONFIG_SRC")
+	setString(&c.Replacer.XrayDst, "MARZBAN_XRAY_CONFIG_PATH")
+	setString(&c.Replacer.EnvSrc, "MARZBAN_ENV_SRC")
+	setString(&c.Replacer.EnvDst, "MARZBAN_ENV_PATH")
+	setString(&c.User.Username, "MARZBAN_USER")
+	setString(&c.User.Expire, "MARZBAN_USER_EXPIRE")
+	setString(&c.User.Note, "MARZBAN_USER_NOTE")
+	setString(&c.Notify.Telegram.BotToken, "MARZBAN_TELEGRAM_BOT_TOKEN")
+	setString(&c.Notify.Telegram.ChatID, "MARZBAN_TELEGRAM_CHAT_ID")
+
+	return errors.Join(
+		setDuration(&c.Client.Timeout, "MARZBAN_TIMEOUT"),
+		setBool(&c.Client.InsecureSkipVerify, "MARZBAN_INSECURE_SKIP_VERIFY"),
+		setFloat(&c.Client.RateLimit, "MARZBAN_RATE_LIMIT"),
+		setInt(&c.Client.MaxRetries, "MARZBAN_MAX_RETRIES"),
+		setBool(&c.Installer.Skip, "MARZBAN_INSTALL_SKIP"),
+		setBool(&c.Installer.Force, "MARZBAN_INSTALL_FORCE"),
+		setDuration(&c.Installer.Timeout, "MARZBAN_INSTALL_TIMEOUT"),
+		setFloat(&c.User.DataLimitGB, "MARZBAN_USER_DATA_LIMIT_GB"),
+		setBool(&c.Replacer.ShowDiff, "MARZBAN_SHOW_DIFF"),
+		setBool(&c.Replacer.CreateDirs, "MARZBAN_CREATE_DIRS"),
+		setBool(&c.Replacer.RestartAfterReplace, "MARZBAN_RESTART_AFTER_REPLACE"),
+		setBool(&c.DryRun, "MARZBAN_DRY_RUN"),
+	)
+}
+
+// Validate reports settings that cannot work together.
+func (c *Config) Validate() error {
+	if c.Client.BaseURL != "" {
+		u, err := url.Parse(c.Client.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("client base_url %q must be an absolute URL", c.Client.BaseURL)
+		}
+	}
+
+	// The client only falls back to admin/admin when both are empty.
+	if (c.Client.Username == "") != (c.Client.Password == "") {
+		return errors.New("client username and password must be set together")
+	}
+
+	// NaN and Inf only get here through MARZBAN_USER_DATA_LIMIT_GB; JSON
+	// cannot express them.
+	gb := c.User.DataLimitGB
+	if gb < 0 || math.IsNaN(gb) || math.IsInf(gb, 0) {
+		return fmt.Errorf("user data_limit_gb %v must be a non-negative number", gb)
+	}
+
+	if c.Notify.Telegram.BotToken != "" && c.Notify.Telegram.ChatID == "" {
+		return errors.New("notify telegram chat_id is required with bot_token")
+	}
+
+	return nil
+}
+
+func setString(dst *string, key string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*dst = value
+	}
+}
+
+func setBool(dst *bool, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+
+	*dst = parsed
+	return nil
+}
+
+func setInt(dst *int, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+
+	*dst = parsed
+	return nil
+}
+
+func setFloat(dst *float64, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+
+	*dst = parsed
+	return nil
+}
+
+func setDuration(dst *Duration, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+
+	*dst = Duration(parsed)
+	return nil
+}
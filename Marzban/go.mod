@@ -2,4 +2,14 @@ module Marzban
 
 go 1.24.2
 
-require google.golang.org/protobuf v1.36.6
+require golang.org/x/time v0.12.0
+
+require (
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	github.com/spf13/cobra v1.10.2
+)
+
+require (
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/spf13/pflag v1.0.9 // indirect
+)
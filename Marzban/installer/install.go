@@ -1,21 +1,316 @@
 package installer
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
+	"strings"
 	"time"
 )
 
+const (
+	MARZBAN_SCRIPT_REPO = "https://github.com/Gozargah/Marzban-scripts"
+	DEFAULT_SCRIPT_REF  = "master"
+	MARZBAN_SCRIPT      = "marzban.sh"
+	MARZBAN_SCRIPT_URL  = MARZBAN_SCRIPT_REPO + "/raw/" + DEFAULT_SCRIPT_REF + "/" + MARZBAN_SCRIPT
+	MARZBAN_DIR         = "/opt/marzban"
+)
+
+var (
+	// ErrTimeout is returned when the marzban script does not finish in time.
+	ErrTimeout = errors.New("timed out")
+	// ErrAlreadyInstalled is returned by Install_Marzban on an existing install.
+	ErrAlreadyInstalled = errors.New("marzban is already installed")
+	// ErrChecksumMismatch is returned when the downloaded script does not
+	// match the expected SHA-256 digest.
+	ErrChecksumMismatch = errors.New("script checksum mismatch")
+)
+
+const (
+	DEFAULT_TIMEOUT        = 2 * time.Minute
+	DEFAULT_UPDATE_TIMEOUT = 10 * time.Minute
+)
+
 func Install_Marzban() error {
-	ctx, cencel := context.WithTimeout(context.Background(), 2 * time.Minute)
-	defer cencel()
+	return Install_MarzbanWithTimeout(DEFAULT_TIMEOUT)
+}
+
+// Install_MarzbanCtx is Install_Marzban bound to ctx, so a caller such as a
+// signal handler can abort the install. DEFAULT_TIMEOUT only applies when
+// ctx has no deadline of its own.
+func Install_MarzbanCtx(ctx context.Context) error {
+	return Install_MarzbanWithOptionsCtx(ctx, Options{})
+}
+
+// Install_MarzbanWithTimeout is Install_Marzban with a caller-chosen limit,
+// useful on slow links where pulling the images takes longer than
+// DEFAULT_TIMEOUT.
+func Install_MarzbanWithTimeout(timeout time.Duration) error {
+	return Install_MarzbanWithOptions(Options{Timeout: timeout})
+}
+
+// Install_MarzbanWithOptions returns ErrAlreadyInstalled without running the
+// script when the panel is already present, unless opts.Force is set. The
+// host is checked with DetectOS first, so a missing dependency is reported
+// by name instead of as a failed exec.
+func Install_MarzbanWithOptions(opts Options) error {
+	return Install_MarzbanWithOptionsCtx(context.Background(), opts)
+}
+
+// Install_MarzbanWithOptionsCtx is Install_MarzbanWithOptions bound to ctx;
+// opts.Timeout only applies when ctx has no deadline.
+func Install_MarzbanWithOptionsCtx(ctx context.Context, opts Options) error {
+	info, err := DetectOS()
+	if err != nil {
+		return fmt.Errorf("marzban install: %w", err)
+	}
+	logger.Debug("detected os", "id", info.ID, "version", info.VersionID)
+
+	if !opts.Force {
+		installed, err := IsMarzbanInstalled()
+		if err != nil {
+			return err
+		}
+		if installed {
+			logger.Info("marzban already installed, skipping install")
+			return ErrAlreadyInstalled
+		}
+	}
+
+	return runScript(ctx, opts, MARZBAN_SCRIPT, "install")
+}
+
+// Install_MarzbanVerified installs only if the downloaded script matches
+// expectedSHA256; on a mismatch nothing is executed.
+func Install_MarzbanVerified(expectedSHA256 string) error {
+	return Install_MarzbanWithOptions(Options{ExpectedSHA256: expectedSHA256})
+}
+
+// IsMarzbanInstalled reports whether both the MARZBAN_DIR installation and
+// the marzban CLI are present.
+func IsMarzbanInstalled() (bool, error) {
+	_, err := os.Stat(MARZBAN_DIR)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = exec.LookPath("marzban")
+	if errors.Is(err, exec.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Uninstall_Marzban removes the panel using the uninstall command of the
+// official script. The script output is included in the error on failure.
+func Uninstall_Marzban() error {
+	return Uninstall_MarzbanWithOptions(Options{})
+}
+
+// Uninstall_MarzbanWithOptions honours opts.DryRun, opts.PrivilegeCommand
+// and opts.RemoveData; Force is ignored.
+func Uninstall_MarzbanWithOptions(opts Options) error {
+	return Uninstall_MarzbanWithOptionsCtx(context.Background(), opts)
+}
+
+func Uninstall_MarzbanWithOptionsCtx(ctx context.Context, opts Options) error {
+	// The script asks to confirm the uninstall and then whether to delete
+	// the data directory; without answers it would read an empty stdin and
+	// abort.
+	removeData := "n"
+	if opts.RemoveData {
+		removeData = "y"
+	}
+	opts.input = strings.NewReader("y\n" + removeData + "\n")
+
+	return runScript(ctx, opts, MARZBAN_SCRIPT, "uninstall")
+}
+
+// Update_Marzban pulls the latest panel version, allowing
+// DEFAULT_UPDATE_TIMEOUT for the images to download.
+func Update_Marzban() error {
+	return Update_MarzbanWithTimeout(DEFAULT_UPDATE_TIMEOUT)
+}
+
+func Update_MarzbanWithTimeout(timeout time.Duration) error {
+	return runScript(context.Background(), Options{Timeout: timeout}, MARZBAN_SCRIPT, "update")
+}
+
+// Options controls how the marzban script is run. A zero Timeout means
+// DEFAULT_TIMEOUT and a nil Output means os.Stdout. Timeout is ignored when
+// the context passed to a *Ctx function already has a deadline.
+type Options struct {
+	Timeout time.Duration
+	// Output receives the script's stdout and stderr as they are produced.
+	Output io.Writer
+	// Force runs the install even if IsMarzbanInstalled reports true.
+	Force bool
+	// ExpectedSHA256, when set, is the hex digest the downloaded script must
+	// match before it is run.
+	ExpectedSHA256 string
+	// DryRun writes the command that would run to Output without
+	// downloading or executing the script.
+	DryRun bool
+	// PrivilegeCommand runs the script with elevated rights, e.g. "doas".
+	// Empty means sudo unless already root; PRIVILEGE_NONE disables it.
+	PrivilegeCommand string
+	// ScriptRef pins the branch, tag or commit of MARZBAN_SCRIPT_REPO the
+	// script is taken from; empty means DEFAULT_SCRIPT_REF.
+	ScriptRef string
+	// ScriptURL replaces the GitHub URL of the script being run entirely,
+	// e.g. with an internal mirror; ScriptRef is then ignored.
+	ScriptURL string
+	// RemoveData makes uninstall also delete the panel's data directory,
+	// including its database. It is kept by default.
+	RemoveData bool
+
+	// input answers the script's prompts; nil leaves stdin empty.
+	input io.Reader
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout == 0 {
+		o.Timeout = DEFAULT_TIMEOUT
+	}
+	if o.Output == nil {
+		o.Output = os.Stdout
+	}
+
+	return o
+}
+
+// scriptURL is where script, a file in MARZBAN_SCRIPT_REPO, is downloaded
+// from.
+func (o Options) scriptURL(script string) string {
+	if o.ScriptURL != "" {
+		return o.ScriptURL
+	}
+
+	ref := DEFAULT_SCRIPT_REF
+	if o.ScriptRef != "" {
+		ref = url.PathEscape(o.ScriptRef)
+	}
+
+	return MARZBAN_SCRIPT_REPO + "/raw/" + ref + "/" + script
+}
+
+// runScript runs a subcommand of script, streaming its output to
+// opts.Output and also wrapping it into the returned error. Cancelling ctx
+// kills the script.
+func runScript(ctx context.Context, opts Options, script, command string) error {
+	opts = opts.withDefaults()
+	// Errors and logs name the script, e.g. "marzban-node install".
+	name := strings.TrimSuffix(script, ".sh")
+
+	if opts.DryRun {
+		argv := privileged(opts.PrivilegeCommand, "bash", opts.scriptURL(script), command)
+		_, err := fmt.Fprintf(opts.Output, "dry run: would run %s\n", strings.Join(argv, " "))
+		return err
+	}
+
+	argv := privileged(opts.PrivilegeCommand, "bash")
+	_, err := exec.LookPath(argv[0])
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s not found", name, command, ErrMissingDependency, argv[0])
+	}
+
+	// The limit is reported in errors only when it is ours.
+	limit := ""
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+		limit = " after " + opts.Timeout.String()
+	}
+
+	path, err := fetchScript(ctx, opts.scriptURL(script), opts.ExpectedSHA256)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", name, command, err)
+	}
+	defer os.Remove(path)
 
-	cmd := exec.CommandContext(ctx, "sudo", "bash", "-c", `$(curl -sL https://github.com/Gozargah/Marzban-scripts/raw/master/marzban.sh) @ install`)
-	
-	_, err := cmd.CombinedOutput()
+	argv = append(argv, path, command)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	logger.Info("running marzban script", "script", name, "command", command)
+
+	var output bytes.Buffer
+	cmd.Stdout = io.MultiWriter(opts.Output, &output)
+	cmd.Stderr = cmd.Stdout
+	cmd.Stdin = opts.input
+
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Error("marzban script timed out", "script", name, "command", command)
+		return fmt.Errorf("%s %s: %w%s: %s", name, command, ErrTimeout, limit, output.Bytes())
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		logger.Warn("marzban script cancelled", "script", name, "command", command)
+		return fmt.Errorf("%s %s: %w: %s", name, command, ctx.Err(), output.Bytes())
+	}
 	if err != nil {
-		return err 
+		logger.Error("marzban script failed", "script", name, "command", command, "error", err)
+		return fmt.Errorf("%s %s: %w: %s", name, command, err, output.Bytes())
 	}
 
+	logger.Info("marzban script finished", "script", name, "command", command)
 	return nil
 }
+
+// fetchScript downloads a marzban script to a temporary file and returns
+// its path; the caller removes it. When expectedSHA256 is set the file is
+// removed and ErrChecksumMismatch returned unless the digests agree.
+func fetchScript(ctx context.Context, scriptURL, expectedSHA256 string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", scriptURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: %s", scriptURL, resp.Status)
+	}
+
+	file, err := os.CreateTemp("", "marzban-*.sh")
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if expectedSHA256 != "" && !strings.EqualFold(sum, expectedSHA256) {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, sum, expectedSHA256)
+	}
+
+	return file.Name(), nil
+}
@@ -0,0 +1,47 @@
+package installer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// uninstallScript mimics the prompts of marzban.sh uninstall: it aborts
+// unless the first answer is y and reports the answer to the second.
+const uninstallScript = `
+read -p "Do you really want to uninstall Marzban? (y/n) " confirm || exit 1
+[ "$confirm" = y ] || exit 1
+read -p "Do you want to remove Marzban's data files too? (y/n) " data || exit 1
+echo "uninstalled, remove data: $data"
+`
+
+func TestUninstallAnswersPrompts(t *testing.T) {
+	script := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, uninstallScript)
+	}))
+	defer script.Close()
+
+	for _, removeData := range []bool{false, true} {
+		var output bytes.Buffer
+		err := Uninstall_MarzbanWithOptions(Options{
+			Output:           &output,
+			PrivilegeCommand: PRIVILEGE_NONE,
+			ScriptURL:        script.URL,
+			RemoveData:       removeData,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := "remove data: n"
+		if removeData {
+			want = "remove data: y"
+		}
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("RemoveData %v: output = %q, want %q", removeData, output.String(), want)
+		}
+	}
+}
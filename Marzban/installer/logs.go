@@ -0,0 +1,85 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	LOGS_TIMEOUT      = 30 * time.Second
+	DEFAULT_LOG_LINES = 100
+)
+
+// MarzbanLogs returns the last lines lines of the panel containers' logs,
+// or all of them when lines is not positive.
+func MarzbanLogs(lines int) (string, error) {
+	return MarzbanLogsWithOptions(lines, Options{})
+}
+
+// MarzbanLogsWithOptions honours opts.PrivilegeCommand; the other options
+// are ignored.
+func MarzbanLogsWithOptions(lines int, opts Options) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), LOGS_TIMEOUT)
+	defer cancel()
+
+	var output bytes.Buffer
+	err := composeLogs(ctx, &output, lines, false, opts.PrivilegeCommand)
+	if err != nil {
+		return "", err
+	}
+
+	return output.String(), nil
+}
+
+// StreamMarzbanLogs writes the last lines lines of the panel logs to w and
+// keeps following new ones until ctx is done, like "marzban logs".
+func StreamMarzbanLogs(ctx context.Context, w io.Writer, lines int) error {
+	return StreamMarzbanLogsWithOptions(ctx, w, lines, Options{})
+}
+
+// StreamMarzbanLogsWithOptions honours opts.PrivilegeCommand; the other
+// options are ignored.
+func StreamMarzbanLogsWithOptions(ctx context.Context, w io.Writer, lines int, opts Options) error {
+	err := composeLogs(ctx, w, lines, true, opts.PrivilegeCommand)
+	if ctx.Err() != nil {
+		// Following only ends by cancelling ctx.
+		return nil
+	}
+
+	return err
+}
+
+func composeLogs(ctx context.Context, w io.Writer, lines int, follow bool, privilegeCommand string) error {
+	tail := "all"
+	if lines > 0 {
+		tail = strconv.Itoa(lines)
+	}
+
+	compose := filepath.Join(MARZBAN_DIR, "docker-compose.yml")
+	argv := privileged(privilegeCommand, "docker", "compose", "-f", compose, "logs", "--no-color", "--tail", tail)
+	if follow {
+		argv = append(argv, "--follow")
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("docker compose logs: %w: %s", ErrTimeout, stderr.Bytes())
+	}
+	if err != nil {
+		return fmt.Errorf("docker compose logs: %w: %s", err, stderr.Bytes())
+	}
+
+	return nil
+}
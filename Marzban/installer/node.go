@@ -0,0 +1,55 @@
+package installer
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MARZBAN_NODE_SCRIPT   = "marzban-node.sh"
+	MARZBAN_NODE_DATA_DIR = "/var/lib/marzban-node"
+	// NODE_CLIENT_CERT_PATH is where the node expects the panel's client
+	// certificate, as shown on the panel's node settings page.
+	NODE_CLIENT_CERT_PATH = MARZBAN_NODE_DATA_DIR + "/ssl_client_cert.pem"
+)
+
+// ErrInvalidCertificate is returned when the panel certificate given to
+// Install_MarzbanNode is not a PEM encoded certificate.
+var ErrInvalidCertificate = errors.New("invalid panel certificate")
+
+// Install_MarzbanNode installs marzban-node, the service a panel connects to
+// in order to run Xray on additional servers. panelCertPEM is the client
+// certificate copied from the panel; the script stores it at
+// NODE_CLIENT_CERT_PATH.
+func Install_MarzbanNode(panelCertPEM string) error {
+	return Install_MarzbanNodeWithOptions(panelCertPEM, Options{})
+}
+
+// Install_MarzbanNodeWithOptions honours the same Options as the panel
+// install; ScriptRef and ScriptURL select the marzban-node script. Force is
+// ignored.
+func Install_MarzbanNodeWithOptions(panelCertPEM string, opts Options) error {
+	return Install_MarzbanNodeWithOptionsCtx(context.Background(), panelCertPEM, opts)
+}
+
+func Install_MarzbanNodeWithOptionsCtx(ctx context.Context, panelCertPEM string, opts Options) error {
+	block, _ := pem.Decode([]byte(panelCertPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("marzban-node install: %w", ErrInvalidCertificate)
+	}
+
+	info, err := DetectOS()
+	if err != nil {
+		return fmt.Errorf("marzban-node install: %w", err)
+	}
+	logger.Debug("detected os", "id", info.ID, "version", info.VersionID)
+
+	// The script asks for the certificate and reads it up to the first
+	// empty line.
+	opts.input = strings.NewReader(strings.TrimSpace(panelCertPEM) + "\n\n")
+
+	return runScript(ctx, opts, MARZBAN_NODE_SCRIPT, "install")
+}
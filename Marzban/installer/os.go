@@ -0,0 +1,88 @@
+package installer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const OS_RELEASE_PATH = "/etc/os-release"
+
+// REQUIRED_BINARIES are the programs the marzban script needs on the host.
+// The privilege command (sudo) is checked separately since root needs none.
+var REQUIRED_BINARIES = []string{"bash", "curl"}
+
+// ErrMissingDependency is returned by DetectOS when a required binary is
+// not on PATH.
+var ErrMissingDependency = errors.New("missing dependency")
+
+// OSInfo identifies the host distribution from /etc/os-release.
+type OSInfo struct {
+	// ID is the lower-case distribution name, e.g. "ubuntu" or "debian".
+	ID string
+	// Name is the human readable PRETTY_NAME, e.g. "Ubuntu 22.04.4 LTS".
+	Name string
+	// VersionID is e.g. "22.04"; rolling distributions leave it empty.
+	VersionID string
+}
+
+// DetectOS reads /etc/os-release and checks that every REQUIRED_BINARIES
+// entry is installed. When some are missing it still returns the OSInfo,
+// with an ErrMissingDependency error naming all of them.
+func DetectOS() (OSInfo, error) {
+	var info OSInfo
+
+	file, err := os.Open(OS_RELEASE_PATH)
+	if err != nil {
+		return info, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = unquoteOSRelease(value)
+
+		switch key {
+		case "ID":
+			info.ID = value
+		case "PRETTY_NAME":
+			info.Name = value
+		case "VERSION_ID":
+			info.VersionID = value
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return info, fmt.Errorf("%s: %w", OS_RELEASE_PATH, err)
+	}
+
+	var missing []string
+	for _, name := range REQUIRED_BINARIES {
+		_, err := exec.LookPath(name)
+		if err != nil {
+			missing = append(missing, name+" not found")
+		}
+	}
+	if len(missing) > 0 {
+		return info, fmt.Errorf("%w on %s: %s", ErrMissingDependency, info.Name, strings.Join(missing, ", "))
+	}
+
+	return info, nil
+}
+
+// unquoteOSRelease strips the shell-style quoting os-release values may use.
+func unquoteOSRelease(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+
+	return strings.Trim(value, "'")
+}
@@ -0,0 +1,30 @@
+package installer
+
+import "os"
+
+const (
+	DEFAULT_PRIVILEGE_COMMAND = "sudo"
+	// PRIVILEGE_NONE as Options.PrivilegeCommand runs commands as the
+	// current user.
+	PRIVILEGE_NONE = "none"
+)
+
+// geteuid is os.Geteuid, replaceable so tests can run as either user.
+var geteuid = os.Geteuid
+
+// privileged prefixes args with privilegeCommand. An empty privilegeCommand
+// means DEFAULT_PRIVILEGE_COMMAND, except when already running as root, so
+// containers without sudo work out of the box.
+func privileged(privilegeCommand string, args ...string) []string {
+	switch privilegeCommand {
+	case "":
+		if geteuid() == 0 {
+			return args
+		}
+		privilegeCommand = DEFAULT_PRIVILEGE_COMMAND
+	case PRIVILEGE_NONE:
+		return args
+	}
+
+	return append([]string{privilegeCommand}, args...)
+}
@@ -0,0 +1,101 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPrivileged(t *testing.T) {
+	tests := []struct {
+		name    string
+		euid    int
+		command string
+		want    []string
+	}{
+		{"root skips sudo", 0, "", []string{"bash", "marzban.sh", "install"}},
+		{"user gets sudo", 1000, "", []string{"sudo", "bash", "marzban.sh", "install"}},
+		{"none as user", 1000, PRIVILEGE_NONE, []string{"bash", "marzban.sh", "install"}},
+		{"custom command", 1000, "doas", []string{"doas", "bash", "marzban.sh", "install"}},
+		{"explicit command as root", 0, "doas", []string{"doas", "bash", "marzban.sh", "install"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(orig func() int) { geteuid = orig }(geteuid)
+			geteuid = func() int { return tt.euid }
+
+			got := privileged(tt.command, "bash", "marzban.sh", "install")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("privileged(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakePrivilegeCommand returns a stand-in for sudo that records the command
+// it was asked to run and prints output instead of running it.
+func fakePrivilegeCommand(t *testing.T, output string) (command string, args func() []string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	argsPath := filepath.Join(dir, "args")
+	command = filepath.Join(dir, "fake-sudo")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > '" + argsPath + "'\nprintf '%s' '" + output + "'\n"
+	err := os.WriteFile(command, []byte(script), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return command, func() []string {
+		data, err := os.ReadFile(argsPath)
+		if err != nil {
+			t.Fatalf("privilege command was not used: %v", err)
+		}
+		return strings.Fields(string(data))
+	}
+}
+
+func TestStatusUsesPrivilegeCommand(t *testing.T) {
+	command, args := fakePrivilegeCommand(t, `[{"Service":"marzban","State":"running","Image":"gozargah/marzban:v0.8.4"}]`)
+
+	status, err := MarzbanStatusWithOptions(Options{PrivilegeCommand: command})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Running || status.Version != "v0.8.4" {
+		t.Errorf("status = %+v", status)
+	}
+	if got := args(); len(got) < 3 || got[0] != "docker" || got[1] != "compose" {
+		t.Errorf("ran %q, want docker compose", got)
+	}
+}
+
+func TestLogsUsePrivilegeCommand(t *testing.T) {
+	command, args := fakePrivilegeCommand(t, "marzban-1  | INFO: Application startup complete.")
+
+	logs, err := MarzbanLogsWithOptions(50, Options{PrivilegeCommand: command})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs, "startup complete") {
+		t.Errorf("logs = %q", logs)
+	}
+	got := args()
+	if len(got) < 2 || got[0] != "docker" || !slices.Contains(got, "logs") || !slices.Contains(got, "50") {
+		t.Errorf("ran %q, want docker compose logs --tail 50", got)
+	}
+}
+
+func TestRestartUsesPrivilegeCommand(t *testing.T) {
+	command, args := fakePrivilegeCommand(t, "")
+
+	err := RestartMarzbanWithOptions(Options{PrivilegeCommand: command})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := args(); !slices.Equal(got, []string{"marzban", "restart", "-n"}) {
+		t.Errorf("ran %q, want marzban restart -n", got)
+	}
+}
@@ -0,0 +1,50 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+const DEFAULT_RESTART_TIMEOUT = 2 * time.Minute
+
+// RestartMarzban restarts the panel containers with "marzban restart" so a
+// replaced xray_config.json or .env takes effect.
+func RestartMarzban() error {
+	return RestartMarzbanWithTimeout(DEFAULT_RESTART_TIMEOUT)
+}
+
+func RestartMarzbanWithTimeout(timeout time.Duration) error {
+	return RestartMarzbanWithOptions(Options{Timeout: timeout})
+}
+
+// RestartMarzbanWithOptions honours opts.PrivilegeCommand and opts.Timeout,
+// where 0 means DEFAULT_RESTART_TIMEOUT; the other options are ignored.
+func RestartMarzbanWithOptions(opts Options) error {
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DEFAULT_RESTART_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// -n stops the script from following the logs after the restart.
+	argv := privileged(opts.PrivilegeCommand, "marzban", "restart", "-n")
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	logger.Info("restarting marzban")
+
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Error("marzban restart timed out", "timeout", timeout)
+		return fmt.Errorf("marzban restart: %w after %s: %s", ErrTimeout, timeout, output)
+	}
+	if err != nil {
+		logger.Error("marzban restart failed", "error", err)
+		return fmt.Errorf("marzban restart: %w: %s", err, output)
+	}
+
+	logger.Info("marzban restarted")
+	return nil
+}
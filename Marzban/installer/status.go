@@ -0,0 +1,177 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const STATUS_TIMEOUT = 30 * time.Second
+
+// WaitForMarzbanRunning polls with exponential backoff between these delays,
+// and the panel counts as up once STATUS_STABLE_CHECKS polls in a row see it
+// running.
+const (
+	STATUS_POLL_INITIAL_DELAY = time.Second
+	STATUS_POLL_MAX_DELAY     = 15 * time.Second
+	STATUS_STABLE_CHECKS      = 2
+)
+
+// Status describes the panel containers as reported by docker compose.
+type Status struct {
+	// Running is true when the marzban service container is running.
+	Running bool
+	// Version is the image tag of the marzban service, e.g. "latest".
+	Version    string
+	Containers []Container
+}
+
+type Container struct {
+	Name    string `json:"Name"`
+	Service string `json:"Service"`
+	State   string `json:"State"`
+	Image   string `json:"Image"`
+}
+
+// MarzbanStatus inspects the compose project in MARZBAN_DIR and reports
+// whether the panel is up.
+func MarzbanStatus() (Status, error) {
+	return MarzbanStatusWithOptions(Options{})
+}
+
+// MarzbanStatusWithOptions honours opts.PrivilegeCommand; the other options
+// are ignored.
+func MarzbanStatusWithOptions(opts Options) (Status, error) {
+	return marzbanStatus(context.Background(), opts.PrivilegeCommand)
+}
+
+// WaitForMarzbanRunning polls MarzbanStatus until the panel is steadily
+// running or ctx is done, since the containers take a while to come up
+// after an install. The last Status seen is returned either way; when ctx
+// ends first the error wraps ErrTimeout or context.Canceled.
+func WaitForMarzbanRunning(ctx context.Context) (Status, error) {
+	return WaitForMarzbanRunningWithOptions(ctx, Options{})
+}
+
+// WaitForMarzbanRunningWithOptions honours opts.PrivilegeCommand; the other
+// options are ignored.
+func WaitForMarzbanRunningWithOptions(ctx context.Context, opts Options) (Status, error) {
+	var status Status
+	var lastErr error
+	delay := STATUS_POLL_INITIAL_DELAY
+	stable := 0
+
+	for {
+		current, err := marzbanStatus(ctx, opts.PrivilegeCommand)
+		if err != nil {
+			lastErr = err
+			stable = 0
+			logger.Debug("marzban status failed", "error", err)
+		} else {
+			status, lastErr = current, nil
+			if status.Running {
+				stable++
+			} else {
+				stable = 0
+			}
+			logger.Debug("marzban status", "running", status.Running, "stable", stable)
+		}
+		if stable >= STATUS_STABLE_CHECKS {
+			return status, nil
+		}
+
+		wait := delay
+		if stable > 0 {
+			// Confirm a running panel quickly instead of backing off.
+			wait = STATUS_POLL_INITIAL_DELAY
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err := ctx.Err()
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = ErrTimeout
+			}
+			if lastErr != nil {
+				return status, fmt.Errorf("wait for marzban: %w: %w", err, lastErr)
+			}
+			return status, fmt.Errorf("wait for marzban: %w: not running", err)
+		case <-timer.C:
+		}
+
+		delay = min(delay*2, STATUS_POLL_MAX_DELAY)
+	}
+}
+
+func marzbanStatus(ctx context.Context, privilegeCommand string) (Status, error) {
+	var status Status
+
+	ctx, cancel := context.WithTimeout(ctx, STATUS_TIMEOUT)
+	defer cancel()
+
+	compose := filepath.Join(MARZBAN_DIR, "docker-compose.yml")
+	argv := privileged(privilegeCommand, "docker", "compose", "-f", compose, "ps", "--all", "--format", "json")
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return status, fmt.Errorf("docker compose ps: %w: %s", err, stderr.Bytes())
+	}
+
+	status.Containers, err = parseContainers(output)
+	if err != nil {
+		return status, err
+	}
+
+	for _, c := range status.Containers {
+		if c.Service != "marzban" {
+			continue
+		}
+
+		status.Running = c.State == "running"
+		if i := strings.LastIndex(c.Image, ":"); i >= 0 {
+			status.Version = c.Image[i+1:]
+		}
+	}
+
+	return status, nil
+}
+
+// parseContainers accepts both the JSON array printed by older compose
+// releases and the one-object-per-line output of newer ones.
+func parseContainers(output []byte) ([]Container, error) {
+	var containers []Container
+
+	output = bytes.TrimSpace(output)
+	if bytes.HasPrefix(output, []byte("[")) {
+		err := json.Unmarshal(output, &containers)
+		return containers, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var c Container
+		err := json.Unmarshal(line, &c)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, c)
+	}
+
+	return containers, scanner.Err()
+}
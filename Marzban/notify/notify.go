@@ -0,0 +1,13 @@
+package notify
+
+import (
+	"context"
+
+	"Marzban/client"
+)
+
+// Notifier delivers a newly created user's subscription details somewhere
+// outside the panel. Implement it to add backends such as webhooks or email.
+type Notifier interface {
+	NotifyUserCreated(ctx context.Context, resp client.Response) error
+}
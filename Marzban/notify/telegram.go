@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"Marzban/client"
+)
+
+const TELEGRAM_API_URL = "https://api.telegram.org"
+
+// TelegramNotifier sends the links of created users to a Telegram chat
+// through a bot.
+type TelegramNotifier struct {
+	BotToken string
+	ChatID   string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+func NewTelegramNotifier(botToken, chatID string) *TelegramNotifier {
+	return &TelegramNotifier{BotToken: botToken, ChatID: chatID}
+}
+
+func (t *TelegramNotifier) NotifyUserCreated(ctx context.Context, resp client.Response) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "User %s created\n", resp.Username)
+	if resp.SubscriptionURL != "" {
+		fmt.Fprintf(&text, "\nSubscription: %s\n", resp.SubscriptionURL)
+	}
+	for _, link := range resp.Links {
+		fmt.Fprintf(&text, "\n%s\n", link)
+	}
+
+	return t.send(ctx, text.String())
+}
+
+func (t *TelegramNotifier) send(ctx context.Context, text string) error {
+	data, err := json.Marshal(map[string]string{
+		"chat_id": t.ChatID,
+		"text":    text,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := TELEGRAM_API_URL + "/bot" + t.BotToken + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := t.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// The URL embeds the bot token; keep it out of the error.
+		return fmt.Errorf("telegram sendMessage: %w", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("telegram sendMessage: %s: %s", resp.Status, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package qr
+
+import (
+	"os"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QR_SIZE is the width and height, in pixels, of the rendered PNG.
+const QR_SIZE = 256
+
+// SubscriptionQR renders link, typically a subscription URL, as a PNG QR
+// code that end users can scan into their client app.
+func SubscriptionQR(link string) ([]byte, error) {
+	return qrcode.Encode(link, qrcode.Medium, QR_SIZE)
+}
+
+// WriteQRPNG writes the QR code of link to path as a PNG.
+func WriteQRPNG(link, path string) error {
+	png, err := SubscriptionQR(link)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, png, 0644)
+}
+
+// TerminalQR renders the QR code of link with Unicode half blocks, two
+// modules per character, for printing to a terminal.
+func TerminalQR(link string) (string, error) {
+	code, err := qrcode.New(link, qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+
+	return code.ToSmallString(false), nil
+}
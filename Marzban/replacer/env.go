@@ -0,0 +1,80 @@
+package replacer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrMissingEnvKeys is returned by ValidateEnv when required keys are absent
+// or empty.
+var ErrMissingEnvKeys = errors.New("missing required keys")
+
+// ParseEnv reads a .env file into a map. Blank lines and # comments are
+// skipped, an "export " prefix is allowed and matching single or double
+// quotes around a value are removed.
+func ParseEnv(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", path, lineNumber)
+		}
+
+		env[strings.TrimSpace(key)] = unquoteEnv(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// ValidateEnv checks that every key in required is set to a non-empty value
+// in the .env file at path, and names all the offending keys otherwise.
+func ValidateEnv(path string, required []string) error {
+	env, err := ParseEnv(path)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, key := range required {
+		if env[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: %w: %s", path, ErrMissingEnvKeys, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func unquoteEnv(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if first == last && (first == '"' || first == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,72 @@
+package replacer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testEnv = `# Marzban settings
+UVICORN_PORT = 8000
+export SQLALCHEMY_DATABASE_URL="sqlite:////var/lib/marzban/db.sqlite3"
+XRAY_JSON='/var/lib/marzban/xray_config.json'
+SUDO_USERNAME=
+`
+
+func TestParseEnv(t *testing.T) {
+	path := writeFile(t, t.TempDir(), ".env", testEnv)
+
+	env, err := ParseEnv(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"UVICORN_PORT":            "8000",
+		"SQLALCHEMY_DATABASE_URL": "sqlite:////var/lib/marzban/db.sqlite3",
+		"XRAY_JSON":               "/var/lib/marzban/xray_config.json",
+		"SUDO_USERNAME":           "",
+	}
+	for key, value := range want {
+		if got, ok := env[key]; !ok || got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if len(env) != len(want) {
+		t.Errorf("env = %v", env)
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	path := writeFile(t, t.TempDir(), ".env", testEnv)
+
+	err := ValidateEnv(path, []string{"UVICORN_PORT", "SQLALCHEMY_DATABASE_URL", "XRAY_JSON"})
+	if err != nil {
+		t.Errorf("all keys set: %v", err)
+	}
+
+	// SUDO_USERNAME is present but empty, which the panel treats as unset.
+	err = ValidateEnv(path, []string{"UVICORN_PORT", "SUDO_USERNAME", "SUDO_PASSWORD"})
+	if !errors.Is(err, ErrMissingEnvKeys) {
+		t.Fatalf("err = %v, want ErrMissingEnvKeys", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "SUDO_USERNAME, SUDO_PASSWORD") || strings.Contains(msg, "UVICORN_PORT") {
+		t.Errorf("err = %q, want exactly the two missing keys", msg)
+	}
+}
+
+func TestReplaceEnvRequiredKeys(t *testing.T) {
+	dir := t.TempDir()
+	old := "SQLALCHEMY_DATABASE_URL=sqlite:///db.sqlite3\n"
+	cfg := ReplacerConfig{
+		EnvSrc:          writeFile(t, dir, "src.env", "UVICORN_PORT=8000\n"),
+		EnvDst:          writeFile(t, dir, ".env", old),
+		RequiredEnvKeys: []string{"SQLALCHEMY_DATABASE_URL"},
+	}
+
+	err := Replace_envWithConfig(cfg)
+	if !errors.Is(err, ErrMissingEnvKeys) {
+		t.Fatalf("err = %v, want ErrMissingEnvKeys", err)
+	}
+	assertContents(t, cfg.EnvDst, old)
+}
@@ -0,0 +1,14 @@
+package replacer
+
+import "log/slog"
+
+var logger = slog.New(slog.DiscardHandler)
+
+// SetLogger routes the package's log output to l; nil restores the default,
+// which discards everything. Call it before using the package.
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.DiscardHandler)
+	}
+	logger = l
+}
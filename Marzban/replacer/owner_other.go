@@ -0,0 +1,11 @@
+//go:build !unix
+
+package replacer
+
+import (
+	"io/fs"
+	"os"
+)
+
+// chownLike is a no-op where files have no uid/gid.
+func chownLike(f *os.File, info fs.FileInfo) {}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package replacer
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// chownLike gives f the uid/gid of info. Only root may hand files to other
+// users, so a failure is logged rather than returned.
+func chownLike(f *os.File, info fs.FileInfo) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+
+	err := f.Chown(int(stat.Uid), int(stat.Gid))
+	if err != nil {
+		logger.Warn("could not preserve owner", "path", f.Name(), "error", err)
+	}
+}
@@ -1,13 +1,234 @@
 package replacer
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"Marzban/installer"
+)
+
+const (
+	DEFAULT_XRAY_SRC = "xray_config.json"
+	DEFAULT_XRAY_DST = "/var/lib/marzban/xray_config.json"
+	DEFAULT_ENV_SRC  = ".env"
+	DEFAULT_ENV_DST  = "/opt/marzban/.env"
 )
 
+// Backups are named path.bak-<time>. The fixed-width nanosecond format
+// keeps two replaces within the same second apart and still sorts
+// chronologically, also next to backups named with whole seconds only.
+const (
+	BACKUP_SUFFIX      = ".bak-"
+	BACKUP_TIME_FORMAT = "20060102150405.000000000"
+)
+
+// Modes given to a destination that does not exist yet. .env carries the
+// panel's secrets, so it is only readable by its owner.
+const (
+	DEFAULT_FILE_MODE fs.FileMode = 0644
+	ENV_FILE_MODE     fs.FileMode = 0600
+	DEFAULT_DIR_MODE  fs.FileMode = 0755
+)
+
+// ErrSameFile is returned when the source and destination of a replace are
+// the same file, which would leave nothing to restore from.
+var ErrSameFile = errors.New("source and destination are the same file")
+
+// ReplacerConfig holds the source and destination of each managed config
+// file. Empty fields fall back to the DEFAULT_* paths.
+type ReplacerConfig struct {
+	XraySrc string
+	XrayDst string
+	EnvSrc  string
+	EnvDst  string
+	// DryRun reports which files would be overwritten, with a diff, to
+	// Output instead of writing anything.
+	DryRun bool
+	// ShowDiff writes the diff of each file to Output before replacing it.
+	ShowDiff bool
+	// CreateDirs creates missing destination directories instead of
+	// failing, e.g. when replacing before the install has created them.
+	CreateDirs bool
+	// Output receives dry-run reports and diffs; nil means os.Stdout.
+	Output io.Writer
+	// RequiredEnvKeys must all be set to non-empty values in EnvSrc, e.g.
+	// SQLALCHEMY_DATABASE_URL, or Replace_env refuses to overwrite EnvDst.
+	RequiredEnvKeys []string
+	// RestartAfterReplace restarts Marzban once the file is in place so the
+	// change takes effect.
+	RestartAfterReplace bool
+	// PrivilegeCommand runs the restart as root, like
+	// installer.Options.PrivilegeCommand.
+	PrivilegeCommand string
+}
+
+func (c ReplacerConfig) withDefaults() ReplacerConfig {
+	if c.XraySrc == "" {
+		c.XraySrc = DEFAULT_XRAY_SRC
+	}
+	if c.XrayDst == "" {
+		c.XrayDst = DEFAULT_XRAY_DST
+	}
+	if c.EnvSrc == "" {
+		c.EnvSrc = DEFAULT_ENV_SRC
+	}
+	if c.EnvDst == "" {
+		c.EnvDst = DEFAULT_ENV_DST
+	}
+	if c.Output == nil {
+		c.Output = os.Stdout
+	}
+
+	return c
+}
+
 func Replace_xray() error {
-	dstPath := "/var/lib/marzban/xray_config.json"
-	srcPath := "xray_config.json"
+	return Replace_xrayWithConfig(ReplacerConfig{})
+}
+
+func Replace_xrayWithConfig(cfg ReplacerConfig) error {
+	cfg = cfg.withDefaults()
+
+	err := validateJSON(cfg.XraySrc)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.replace(cfg.XraySrc, cfg.XrayDst, DEFAULT_FILE_MODE)
+	if err != nil {
+		return err
+	}
+
+	return cfg.restart()
+}
+
+func Replace_env() error {
+	return Replace_envWithConfig(ReplacerConfig{})
+}
+
+func Replace_envWithConfig(cfg ReplacerConfig) error {
+	cfg = cfg.withDefaults()
+
+	if len(cfg.RequiredEnvKeys) > 0 {
+		err := ValidateEnv(cfg.EnvSrc, cfg.RequiredEnvKeys)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := cfg.replace(cfg.EnvSrc, cfg.EnvDst, ENV_FILE_MODE)
+	if err != nil {
+		return err
+	}
+
+	return cfg.restart()
+}
+
+// Replace_allWithConfig replaces both xray_config.json and .env. With
+// RestartAfterReplace, Marzban is restarted once at the end, and only when
+// both files were replaced.
+func Replace_allWithConfig(cfg ReplacerConfig) error {
+	restart := cfg.RestartAfterReplace
+	cfg.RestartAfterReplace = false
+
+	var errs []error
+	err := Replace_xrayWithConfig(cfg)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("replace xray config: %w", err))
+	}
+	err = Replace_envWithConfig(cfg)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("replace env: %w", err))
+	}
+
+	if len(errs) > 0 {
+		if restart {
+			logger.Warn("not restarting marzban after a failed replace")
+		}
+		return errors.Join(errs...)
+	}
+
+	cfg.RestartAfterReplace = restart
+	return cfg.withDefaults().restart()
+}
+
+// restart restarts Marzban when RestartAfterReplace is set.
+func (c ReplacerConfig) restart() error {
+	if !c.RestartAfterReplace {
+		return nil
+	}
+	if c.DryRun {
+		_, err := fmt.Fprintln(c.Output, "dry run: would restart marzban")
+		return err
+	}
+
+	return installer.RestartMarzbanWithOptions(installer.Options{PrivilegeCommand: c.PrivilegeCommand})
+}
+
+// replace applies the DryRun, ShowDiff and CreateDirs settings around
+// replaceFile.
+func (c ReplacerConfig) replace(srcPath, dstPath string, newMode fs.FileMode) error {
+	err := checkSameFile(srcPath, dstPath)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(dstPath)
+	_, err = os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !c.CreateDirs {
+			return fmt.Errorf("directory %s does not exist; marzban may not have finished installing: %w", dir, err)
+		}
+		if c.DryRun {
+			_, err = fmt.Fprintf(c.Output, "dry run: would create directory %s\n", dir)
+			if err != nil {
+				return err
+			}
+		} else {
+			err = os.MkdirAll(dir, DEFAULT_DIR_MODE)
+			if err != nil {
+				return err
+			}
+			logger.Info("created directory", "path", dir)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if c.DryRun {
+		return preview(c.Output, srcPath, dstPath)
+	}
+	if c.ShowDiff {
+		err = showDiff(c.Output, srcPath, dstPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return replaceFile(srcPath, dstPath, newMode)
+}
+
+// ReplaceFile overwrites dstPath with the contents of srcPath, keeping the
+// previous file as a backup. The new contents are written to a temporary
+// file next to dstPath and renamed into place, so dstPath is never left
+// half-written. The original file mode and, where permitted, owner are
+// preserved; a new file gets DEFAULT_FILE_MODE.
+func ReplaceFile(srcPath, dstPath string) error {
+	return replaceFile(srcPath, dstPath, DEFAULT_FILE_MODE)
+}
+
+func replaceFile(srcPath, dstPath string, newMode fs.FileMode) error {
+	err := checkSameFile(srcPath, dstPath)
+	if err != nil {
+		return err
+	}
 
 	src, err := os.Open(srcPath)
 	if err != nil {
@@ -15,40 +236,228 @@ func Replace_xray() error {
 	}
 	defer src.Close()
 
-	dst, err := os.Open(dstPath)
+	return replaceFrom(src, srcPath, dstPath, newMode)
+}
+
+// replaceFrom does the work of ReplaceFile for any reader; srcName only
+// appears in logs.
+func replaceFrom(src io.Reader, srcName, dstPath string, newMode fs.FileMode) error {
+	mode := newMode
+	info, err := os.Stat(dstPath)
+	if err == nil {
+		mode = info.Mode().Perm()
+	} else if errors.Is(err, fs.ErrNotExist) {
+		info = nil
+	} else {
+		return err
+	}
+
+	backupPath, err := backup(dstPath)
 	if err != nil {
 		return err
 	}
-	defer dst.Close()
 
-	_, err = io.Copy(dst, src)
+	err = writeAtomic(dstPath, src, mode, info)
 	if err != nil {
+		// dstPath is untouched, so the backup is not needed.
+		if backupPath != "" {
+			os.Remove(backupPath)
+		}
+		logger.Error("replace failed", "src", srcName, "dst", dstPath, "error", err)
 		return err
 	}
 
+	logger.Info("replaced file", "src", srcName, "dst", dstPath, "backup", backupPath)
 	return nil
 }
 
-func Replace_env() error {
-	dstPath := "/opt/marzban/.env"
-	srcPath := ".env"
+// checkSameFile returns ErrSameFile when srcPath and dstPath name the same
+// file, either literally or through a symlink or hard link.
+func checkSameFile(srcPath, dstPath string) error {
+	srcAbs, err := filepath.Abs(srcPath)
+	if err != nil {
+		return err
+	}
+	dstAbs, err := filepath.Abs(dstPath)
+	if err != nil {
+		return err
+	}
+	if srcAbs == dstAbs {
+		return fmt.Errorf("%s: %w", srcPath, ErrSameFile)
+	}
 
-	src, err := os.Open(srcPath)
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := os.Stat(dstPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if os.SameFile(srcInfo, dstInfo) {
+		return fmt.Errorf("%s and %s: %w", srcPath, dstPath, ErrSameFile)
+	}
+
+	return nil
+}
+
+// preview writes to w what ReplaceFile(srcPath, dstPath) would change.
+func preview(w io.Writer, srcPath, dstPath string) error {
+	_, err := os.Stat(dstPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, err = fmt.Fprintf(w, "dry run: would create %s from %s\n", dstPath, srcPath)
+		return err
+	}
 	if err != nil {
 		return err
 	}
-	defer src.Close()
 
-	dst, err := os.Open(dstPath)
+	diff, err := DiffFile(srcPath, dstPath)
 	if err != nil {
 		return err
 	}
-	defer dst.Close()
+	if diff == "" {
+		_, err = fmt.Fprintf(w, "dry run: %s is already up to date\n", dstPath)
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "dry run: would overwrite %s with %s\n%s", dstPath, srcPath, diff)
+	return err
+}
+
+// showDiff writes the changes ReplaceFile(srcPath, dstPath) is about to make
+// to w.
+func showDiff(w io.Writer, srcPath, dstPath string) error {
+	diff, err := DiffFile(srcPath, dstPath)
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		return nil
+	}
+
+	_, err = io.WriteString(w, diff)
+	return err
+}
 
-	_, err = io.Copy(dst, src)
+// RestoreBackup puts the most recent backup of path back in place.
+func RestoreBackup(path string) error {
+	backups, err := filepath.Glob(path + BACKUP_SUFFIX + "*")
 	if err != nil {
 		return err
 	}
+	if len(backups) == 0 {
+		return fmt.Errorf("no backup found for %s", path)
+	}
+
+	// The timestamp format sorts chronologically.
+	sort.Strings(backups)
+	return os.Rename(backups[len(backups)-1], path)
+}
+
+// validateJSON refuses a malformed config before anything is overwritten.
+func validateJSON(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return validateJSONBytes(path, data)
+}
+
+func validateJSONBytes(path string, data []byte) error {
+	var config map[string]any
+	err := json.Unmarshal(data, &config)
+	if err != nil {
+		return fmt.Errorf("%s is not valid JSON: %w", path, err)
+	}
 
 	return nil
 }
+
+// now is time.Now, replaceable so tests can make backups collide.
+var now = time.Now
+
+// backup copies an existing file at path to path.bak-<time> and returns
+// the backup location, or "" when there was nothing to back up.
+func backup(path string) (string, error) {
+	src, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// On a coarse clock two backups can still share a timestamp; step past
+	// any that exist rather than overwrite them.
+	backupTime := now()
+	backupPath := path + BACKUP_SUFFIX + backupTime.Format(BACKUP_TIME_FORMAT)
+	for {
+		_, err = os.Lstat(backupPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		backupTime = backupTime.Add(time.Nanosecond)
+		backupPath = path + BACKUP_SUFFIX + backupTime.Format(BACKUP_TIME_FORMAT)
+	}
+
+	err = writeAtomic(backupPath, src, info.Mode().Perm(), info)
+	if err != nil {
+		return "", err
+	}
+
+	return backupPath, nil
+}
+
+// writeAtomic writes r to a temporary file in the directory of path and
+// renames it over path once everything has been flushed to disk. When owner
+// is not nil the file is given the same uid/gid.
+func writeAtomic(path string, r io.Reader, mode fs.FileMode, owner fs.FileInfo) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Chmod(mode)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if owner != nil {
+		chownLike(tmp, owner)
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,385 @@
+package replacer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile creates a file in dir and returns its path.
+func writeFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, []byte(contents), DEFAULT_FILE_MODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func assertContents(t *testing.T, path, want string) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s = %q, want %q", path, got, want)
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	contents := "{\"log\": {\"loglevel\": \"warning\"}}\n\x00binary\xff"
+	src := writeFile(t, dir, "src.json", contents)
+	dst := writeFile(t, dir, "dst.json", "old contents")
+
+	err := ReplaceFile(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContents(t, dst, contents)
+	assertContents(t, src, contents)
+
+	backups, _ := filepath.Glob(dst + BACKUP_SUFFIX + "*")
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v, want one", backups)
+	}
+	assertContents(t, backups[0], "old contents")
+}
+
+func TestReplaceFileCreatesDestination(t *testing.T) {
+	dir := t.TempDir()
+	src := writeFile(t, dir, "src.json", "{}")
+	dst := filepath.Join(dir, "dst.json")
+
+	err := ReplaceFile(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContents(t, dst, "{}")
+	backups, _ := filepath.Glob(dst + BACKUP_SUFFIX + "*")
+	if len(backups) != 0 {
+		t.Errorf("backups = %v, want none for a new file", backups)
+	}
+}
+
+func TestReplaceFileLarge(t *testing.T) {
+	dir := t.TempDir()
+	contents := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	src := writeFile(t, dir, "src", string(contents))
+	dst := writeFile(t, dir, "dst", "")
+
+	err := ReplaceFile(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, contents) {
+		t.Errorf("dst has %d bytes, want the %d bytes of src", len(got), len(contents))
+	}
+}
+
+func TestReplaceXrayRejectsTruncatedJSON(t *testing.T) {
+	dir := t.TempDir()
+	old := `{"inbounds": []}`
+	src := writeFile(t, dir, "xray_config.json", `{"inbounds": [{"tag": "VLESS TCP`)
+	dst := writeFile(t, dir, "installed.json", old)
+
+	err := Replace_xrayWithConfig(ReplacerConfig{XraySrc: src, XrayDst: dst})
+	if err == nil {
+		t.Fatal("expected an error for truncated JSON")
+	}
+
+	assertContents(t, dst, old)
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("dir holds %d entries, want only src and dst", len(entries))
+	}
+}
+
+func TestReplacerConfigCustomPaths(t *testing.T) {
+	dir := t.TempDir()
+	cfg := ReplacerConfig{
+		XraySrc:    writeFile(t, dir, "my-xray.json", `{"outbounds": []}`),
+		XrayDst:    filepath.Join(dir, "srv", "marzban", "xray_config.json"),
+		EnvSrc:     writeFile(t, dir, "my.env", "UVICORN_PORT=8000\n"),
+		EnvDst:     filepath.Join(dir, "srv", "marzban", ".env"),
+		CreateDirs: true,
+	}
+
+	err := Replace_allWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContents(t, cfg.XrayDst, `{"outbounds": []}`)
+	assertContents(t, cfg.EnvDst, "UVICORN_PORT=8000\n")
+}
+
+func TestReplacerConfigMissingDirectory(t *testing.T) {
+	dir := t.TempDir()
+	cfg := ReplacerConfig{
+		XraySrc: writeFile(t, dir, "xray.json", "{}"),
+		XrayDst: filepath.Join(dir, "missing", "xray_config.json"),
+	}
+
+	err := Replace_xrayWithConfig(cfg)
+	if err == nil {
+		t.Fatal("expected an error without CreateDirs")
+	}
+	_, err = os.Stat(filepath.Dir(cfg.XrayDst))
+	if !os.IsNotExist(err) {
+		t.Errorf("directory was created without CreateDirs: %v", err)
+	}
+}
+
+func TestReplacerConfigDefaults(t *testing.T) {
+	cfg := ReplacerConfig{XrayDst: "/srv/xray.json"}.withDefaults()
+
+	if cfg.XraySrc != DEFAULT_XRAY_SRC || cfg.XrayDst != "/srv/xray.json" {
+		t.Errorf("xray paths = %q, %q", cfg.XraySrc, cfg.XrayDst)
+	}
+	if cfg.EnvSrc != DEFAULT_ENV_SRC || cfg.EnvDst != DEFAULT_ENV_DST {
+		t.Errorf("env paths = %q, %q", cfg.EnvSrc, cfg.EnvDst)
+	}
+}
+
+// failingReader yields n bytes and then fails, like a copy cut short.
+type failingReader struct{ n int }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errors.New("copy interrupted")
+	}
+	n := min(len(p), r.n)
+	for i := range n {
+		p[i] = 'x'
+	}
+	r.n -= n
+	return n, nil
+}
+
+func TestReplaceInterruptedLeavesDestination(t *testing.T) {
+	dir := t.TempDir()
+	dst := writeFile(t, dir, "xray_config.json", "old contents")
+
+	err := replaceFrom(&failingReader{n: 1 << 20}, "reader", dst, DEFAULT_FILE_MODE)
+	if err == nil {
+		t.Fatal("expected the interrupted copy to fail")
+	}
+
+	assertContents(t, dst, "old contents")
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("dir holds %d entries, want no temporary files or backups left", len(entries))
+	}
+}
+
+func TestReplaceFileIsNeverHalfWritten(t *testing.T) {
+	dir := t.TempDir()
+	old := string(bytes.Repeat([]byte("o"), 1<<20))
+	contents := string(bytes.Repeat([]byte("n"), 4<<20))
+	src := writeFile(t, dir, "src", contents)
+	dst := writeFile(t, dir, "dst", old)
+
+	done := make(chan struct{})
+	seen := make(chan error, 1)
+	go func() {
+		defer close(seen)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			got, err := os.ReadFile(dst)
+			if err != nil {
+				seen <- err
+				return
+			}
+			if string(got) != old && string(got) != contents {
+				seen <- fmt.Errorf("read a %d byte destination, want %d or %d", len(got), len(old), len(contents))
+				return
+			}
+		}
+	}()
+
+	err := ReplaceFile(src, dst)
+	close(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-seen; err != nil {
+		t.Error(err)
+	}
+
+	assertContents(t, dst, contents)
+	tmps, _ := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if len(tmps) != 0 {
+		t.Errorf("temporary files left behind: %v", tmps)
+	}
+}
+
+func assertMode(t *testing.T, path string, want fs.FileMode) {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != want {
+		t.Errorf("%s mode = %v, want %v", path, got, want)
+	}
+}
+
+func TestReplaceFileKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	src := writeFile(t, dir, "src.env", "UVICORN_PORT=8000\n")
+	dst := writeFile(t, dir, ".env", "UVICORN_PORT=80\n")
+	err := os.Chmod(dst, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ReplaceFile(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMode(t, dst, 0640)
+	backups, _ := filepath.Glob(dst + BACKUP_SUFFIX + "*")
+	for _, backup := range backups {
+		assertMode(t, backup, 0640)
+	}
+}
+
+func TestReplaceEnvNewFileIsPrivate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := ReplacerConfig{
+		EnvSrc: writeFile(t, dir, "src.env", "SQLALCHEMY_DATABASE_URL=sqlite:///db.sqlite3\n"),
+		EnvDst: filepath.Join(dir, ".env"),
+	}
+
+	err := Replace_envWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMode(t, cfg.EnvDst, ENV_FILE_MODE)
+}
+
+func TestReplaceFileSameFile(t *testing.T) {
+	dir := t.TempDir()
+	contents := `{"inbounds": []}`
+	path := writeFile(t, dir, "xray_config.json", contents)
+	link := filepath.Join(dir, "link.json")
+	err := os.Symlink(path, link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unclean := dir + "/./sub/../xray_config.json"
+	err = os.Mkdir(filepath.Join(dir, "sub"), DEFAULT_DIR_MODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dst := range []string{path, unclean, link} {
+		err := ReplaceFile(path, dst)
+		if !errors.Is(err, ErrSameFile) {
+			t.Errorf("ReplaceFile(%s, %s) = %v, want ErrSameFile", path, dst, err)
+		}
+		assertContents(t, path, contents)
+	}
+
+	backups, _ := filepath.Glob(path + BACKUP_SUFFIX + "*")
+	if len(backups) != 0 {
+		t.Errorf("backups = %v, want none", backups)
+	}
+}
+
+func TestReplaceFileKeepsEveryBackup(t *testing.T) {
+	dir := t.TempDir()
+	dst := writeFile(t, dir, "xray_config.json", "v1")
+
+	// Back to back, these used to land in the same second and share a
+	// backup name, losing v1.
+	for _, contents := range []string{"v2", "v3", "v4"} {
+		src := writeFile(t, dir, "src.json", contents)
+		err := ReplaceFile(src, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, _ := filepath.Glob(dst + BACKUP_SUFFIX + "*")
+	if len(backups) != 3 {
+		t.Fatalf("backups = %v, want three", backups)
+	}
+
+	for _, want := range []string{"v3", "v2", "v1"} {
+		err := RestoreBackup(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertContents(t, dst, want)
+	}
+}
+
+func TestBackupTakenTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, ".env", "new")
+
+	fixed := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	defer func(orig func() time.Time) { now = orig }(now)
+	now = func() time.Time { return fixed }
+
+	taken := path + BACKUP_SUFFIX + fixed.Format(BACKUP_TIME_FORMAT)
+	writeFile(t, dir, filepath.Base(taken), "older")
+
+	backupPath, err := backup(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := path + BACKUP_SUFFIX + fixed.Add(time.Nanosecond).Format(BACKUP_TIME_FORMAT); backupPath != want {
+		t.Errorf("backup = %s, want %s", backupPath, want)
+	}
+	assertContents(t, taken, "older")
+	assertContents(t, backupPath, "new")
+}
+
+func TestRestartAfterReplaceUsesPrivilegeCommand(t *testing.T) {
+	dir := t.TempDir()
+	argsPath := filepath.Join(dir, "args")
+	command := writeFile(t, dir, "fake-sudo", "#!/bin/sh\necho \"$@\" > '"+argsPath+"'\n")
+	err := os.Chmod(command, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Replace_xrayWithConfig(ReplacerConfig{
+		XraySrc:             writeFile(t, dir, "src.json", "{}"),
+		XrayDst:             filepath.Join(dir, "xray_config.json"),
+		RestartAfterReplace: true,
+		PrivilegeCommand:    command,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, argsPath, "marzban restart -n\n")
+}
@@ -0,0 +1,45 @@
+package replacer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"text/template"
+)
+
+// RenderAndReplace executes the text/template at srcTemplate with data and
+// places the result at dst the same way ReplaceFile does, so one templated
+// .env or xray_config.json can be reused across servers. Referencing a key
+// missing from data is an error rather than an empty value. A rendered
+// xray_config.json is checked to be valid JSON before anything is written.
+func RenderAndReplace(srcTemplate, dst string, data any) error {
+	err := checkSameFile(srcTemplate, dst)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(filepath.Base(srcTemplate)).Option("missingkey=error").ParseFiles(srcTemplate)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, data)
+	if err != nil {
+		return fmt.Errorf("render %s: %w", srcTemplate, err)
+	}
+
+	if filepath.Ext(dst) == ".json" {
+		err = validateJSONBytes(srcTemplate, out.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	mode := DEFAULT_FILE_MODE
+	if filepath.Base(dst) == filepath.Base(DEFAULT_ENV_DST) {
+		mode = ENV_FILE_MODE
+	}
+
+	return replaceFrom(&out, srcTemplate, dst, mode)
+}
@@ -0,0 +1,125 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Marzban/client"
+	"Marzban/installer"
+	"Marzban/notify"
+	"Marzban/replacer"
+)
+
+const (
+	DEFAULT_PANEL_WAIT_TIMEOUT = 2 * time.Minute
+	PANEL_WAIT_INTERVAL        = 2 * time.Second
+)
+
+// SetupConfig describes a full provisioning run: installing the panel,
+// placing its config files and creating the first user.
+type SetupConfig struct {
+	Install installer.Options
+	// SkipInstall leaves the existing installation alone.
+	SkipInstall bool
+
+	Replacer replacer.ReplacerConfig
+
+	Client client.Config
+	// User is created once the panel is configured; an empty Username skips
+	// the step.
+	User client.CreateUserRequest
+	// OnUserCreated, when set, receives the panel's answer for User.
+	OnUserCreated func(client.Response)
+	// Notifier, when set, is sent the created user's links.
+	Notifier notify.Notifier
+	// PanelWaitTimeout bounds how long to wait for the panel to come up
+	// before creating User; 0 means DEFAULT_PANEL_WAIT_TIMEOUT.
+	PanelWaitTimeout time.Duration
+	// DryRun previews the install and file replacements and skips creating
+	// the user. It overrides Install.DryRun and Replacer.DryRun.
+	DryRun bool
+}
+
+// RunSetup installs Marzban, replaces xray_config.json and .env, then creates
+// the initial user. A failed install stops the run; later steps all run and
+// their errors are joined.
+func RunSetup(cfg SetupConfig) error {
+	return RunSetupCtx(context.Background(), cfg)
+}
+
+// RunSetupCtx is RunSetup bound to ctx; cancelling it aborts the install or
+// the wait for the panel.
+func RunSetupCtx(ctx context.Context, cfg SetupConfig) error {
+	if cfg.DryRun {
+		cfg.Install.DryRun = true
+		cfg.Replacer.DryRun = true
+	}
+
+	if !cfg.SkipInstall {
+		logger.Info("installing marzban")
+		err := installer.Install_MarzbanWithOptionsCtx(ctx, cfg.Install)
+		if err != nil && !errors.Is(err, installer.ErrAlreadyInstalled) {
+			return fmt.Errorf("install: %w", err)
+		}
+	}
+
+	var errs []error
+
+	logger.Info("replacing config files")
+
+	err := replacer.Replace_allWithConfig(cfg.Replacer)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.User.Username != "" && cfg.DryRun {
+		logger.Info("dry run: skipping user creation", "username", cfg.User.Username)
+	} else if cfg.User.Username != "" {
+		err = createUser(ctx, cfg)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// createUser waits for the freshly installed panel to answer before
+// creating cfg.User, then hands the result to the hooks.
+func createUser(ctx context.Context, cfg SetupConfig) error {
+	panel := client.NewMarzbanClientWithConfig(cfg.Client)
+	defer panel.Close()
+
+	timeout := cfg.PanelWaitTimeout
+	if timeout == 0 {
+		timeout = DEFAULT_PANEL_WAIT_TIMEOUT
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logger.Info("waiting for panel", "timeout", timeout)
+	err := panel.WaitForPanel(waitCtx, PANEL_WAIT_INTERVAL)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("creating user", "username", cfg.User.Username)
+	resp, err := panel.CreateMarzbanUserCtx(ctx, cfg.User)
+	if err != nil {
+		return fmt.Errorf("create user %s: %w", cfg.User.Username, err)
+	}
+
+	if cfg.OnUserCreated != nil {
+		cfg.OnUserCreated(resp)
+	}
+	if cfg.Notifier != nil {
+		err = cfg.Notifier.NotifyUserCreated(ctx, resp)
+		if err != nil {
+			return fmt.Errorf("notify user %s: %w", cfg.User.Username, err)
+		}
+	}
+
+	return nil
+}